@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// AlertHours specifies the daily window during which the alerts of a
+// site are dispatched.  Alerts and recovery notices raised outside the
+// window are logged, and deferred until it opens; a recovery discards
+// the alerts deferred before it.  A window whose end precedes its start
+// spans midnight.
+type AlertHours struct {
+	Start    string   `json:"start"`    // "15:04"
+	End      string   `json:"end"`      // "15:04"
+	Days     []string `json:"days"`     // "Mon" through "Sun"; all, if empty
+	Timezone string   `json:"timezone"` // IANA name; local, if empty
+}
+
+// alertHoursResolution is the granularity of alert hours, at which
+// deferred alerts are checked for release.
+const alertHoursResolution = time.Minute
+
+// validate checks the window's times, days and timezone.
+func (h *AlertHours) validate() error {
+	if _, err := time.Parse("15:04", h.Start); err != nil {
+		return fmt.Errorf("invalid alert hours start: %s", h.Start)
+	}
+	if _, err := time.Parse("15:04", h.End); err != nil {
+		return fmt.Errorf("invalid alert hours end: %s", h.End)
+	}
+	for _, d := range h.Days {
+		if _, ok := weekdays[strings.ToLower(d)]; !ok {
+			return fmt.Errorf("invalid alert hours day: %s", d)
+		}
+	}
+	if _, err := time.LoadLocation(h.Timezone); err != nil {
+		return fmt.Errorf("invalid alert hours timezone: %s", err.Error())
+	}
+	return nil
+}
+
+// weekdays maps abbreviated day names to weekdays.
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// contains answers whether the given instant falls within the window.
+// The window is assumed to have been validated.
+func (h *AlertHours) contains(t time.Time) bool {
+	loc, _ := time.LoadLocation(h.Timezone)
+	t = t.In(loc)
+	start, _ := time.Parse("15:04", h.Start)
+	end, _ := time.Parse("15:04", h.End)
+	now := t.Hour()*60 + t.Minute()
+	from := start.Hour()*60 + start.Minute()
+	to := end.Hour()*60 + end.Minute()
+
+	// For a window spanning midnight, the early hours belong to the
+	// window that opened on the previous day.
+	day := t.Weekday()
+	var in bool
+	switch {
+	case from <= to:
+		in = now >= from && now < to
+	case now >= from:
+		in = true
+	case now < to:
+		in = true
+		day = (day + 6) % 7
+	}
+	if !in || len(h.Days) == 0 {
+		return in
+	}
+	for _, d := range h.Days {
+		if weekdays[strings.ToLower(d)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// deferAlert holds the given alert until the alert hours of its site
+// open.  Only the latest alert per site and service is held.
+func (m *Monitor) deferAlert(a *Alert) {
+	zLog.Warn("alert",
+		zap.String("uri", a.Site.Server),
+		zap.String("service", a.Service),
+		zap.String("error", a.Err.Error()),
+		zap.String("deferred", "outside alert hours"))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.deferred == nil {
+		m.deferred = make(map[string]*Alert)
+	}
+	m.deferred[a.Site.key()+" "+a.Service] = a
+	if m.conf.DigestIntervalHours > 0 && !a.Recovery {
+		m.digestDeferred = append(m.digestDeferred, a)
+	}
+}
+
+// dropDeferred discards the alerts held for the given site.
+func (m *Monitor) dropDeferred(site *Site) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, a := range m.deferred {
+		if a.Site.key() == site.key() {
+			delete(m.deferred, k)
+		}
+	}
+}
+
+// flushDeferred dispatches the held alerts whose sites' alert hours have
+// opened, unless their alerts are suppressed by then.
+func (m *Monitor) flushDeferred() {
+	now := m.now()
+	var due []*Alert
+	m.mu.Lock()
+	for k, a := range m.deferred {
+		if a.Site.AlertHours.contains(now) {
+			due = append(due, a)
+			delete(m.deferred, k)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, a := range due {
+		if a.Recovery {
+			if m.paused.Load() || m.inStartupGrace() {
+				continue
+			}
+		} else if m.suppressed(a.Site, a.Service, a.Err) {
+			continue
+		}
+		zLog.Info("alert",
+			zap.String("uri", a.Site.Server),
+			zap.String("service", a.Service),
+			zap.String("at", a.At.Format(time.RFC3339)),
+			zap.String("released", "alert hours opened"))
+		m.dispatch(a)
+	}
+}
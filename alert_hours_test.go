@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingNotifier records the alerts offered to it.
+type recordingNotifier struct {
+	name string
+
+	mu     sync.Mutex
+	alerts []*Alert
+}
+
+func (n *recordingNotifier) Name() string {
+	return n.name
+}
+
+func (n *recordingNotifier) Notify(a *Alert) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.alerts = append(n.alerts, a)
+	return nil
+}
+
+func (n *recordingNotifier) count() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.alerts)
+}
+
+func TestDeferredAlerts(t *testing.T) {
+	tests := []struct {
+		name   string
+		before func(m *Monitor, site *Site)
+		want   int
+	}{
+		{"released", func(m *Monitor, site *Site) {}, 1},
+		{"paused", func(m *Monitor, site *Site) { m.paused.Store(true) }, 0},
+		{"acknowledged", func(m *Monitor, site *Site) { m.acknowledge(site, m.now().Add(time.Hour)) }, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, clk := newTestMonitor(&Config{HeartbeatSeconds: 60})
+			rn := &recordingNotifier{name: "rec"}
+			m.notifiers = []Notifier{rn}
+			// The clock starts at 09:00 UTC.
+			site := &Site{Name: "a", Server: "h", AlertHours: &AlertHours{Start: "10:00", End: "18:00"}}
+
+			m.alert(site, "tcp", errors.New("down"))
+			m.flushDeferred()
+			if n := rn.count(); n != 0 {
+				t.Fatalf("%d alerts dispatched outside alert hours", n)
+			}
+
+			clk.Advance(time.Hour)
+			tt.before(m, site)
+			m.flushDeferred()
+			if n := rn.count(); n != tt.want {
+				t.Fatalf("%d alerts released, expected %d", n, tt.want)
+			}
+			m.flushDeferred()
+			if n := rn.count(); n != tt.want {
+				t.Fatalf("%d alerts after a second flush, expected %d", n, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeferredRecovery(t *testing.T) {
+	m, clk := newTestMonitor(&Config{HeartbeatSeconds: 60})
+	rn := &recordingNotifier{name: "rec"}
+	m.notifiers = []Notifier{rn}
+	// The clock starts at 09:00 UTC.
+	site := &Site{Name: "a", Protocol: "tcp", Server: "h", AlertHours: &AlertHours{Start: "10:00", End: "18:00"}}
+
+	downSince := m.now()
+	m.alert(site, "tcp", errors.New("down"))
+	m.alert(site, "certificate", errors.New("expired"))
+	clk.Advance(10 * time.Minute)
+	m.notifyRecovery(site, downSince, 5)
+	if n := rn.count(); n != 0 {
+		t.Fatalf("%d notices dispatched outside alert hours", n)
+	}
+
+	clk.Advance(time.Hour)
+	m.flushDeferred()
+	if n := rn.count(); n != 1 {
+		t.Fatalf("%d notices released, expected 1", n)
+	}
+	if !rn.alerts[0].Recovery {
+		t.Fatalf("released %q, expected the recovery", rn.alerts[0].Err)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// bodyDiff summarises the differences between two response bodies,
+// compared line by line.
+type bodyDiff struct {
+	lines     int // in the longer body
+	differing int
+	firstLine int // 1-based; 0, if none differ
+}
+
+// ratio answers the fraction of the lines that differ.
+func (d bodyDiff) ratio() float64 {
+	if d.lines == 0 {
+		return 0
+	}
+	return float64(d.differing) / float64(d.lines)
+}
+
+// diffBodies compares the given bodies line by line.
+func diffBodies(a, b []byte) bodyDiff {
+	la, lb := bytes.Split(a, []byte("\n")), bytes.Split(b, []byte("\n"))
+	d := bodyDiff{lines: max(len(la), len(lb))}
+	for i := 0; i < d.lines; i++ {
+		if i < len(la) && i < len(lb) && bytes.Equal(la[i], lb[i]) {
+			continue
+		}
+		d.differing++
+		if d.firstLine == 0 {
+			d.firstLine = i + 1
+		}
+	}
+	return d
+}
+
+// compareCanary requests the stable endpoint with which the given site,
+// the canary, is to be compared, and answers an error if their responses
+// diverge: in status, or in more than the tolerated fraction of body
+// lines.
+func (m *Monitor) compareCanary(ctx context.Context, site *Site, status int, body []byte) error {
+	hc := &site.HTTPConfig
+	timeout := time.Duration(site.httpTotalTimeoutMillis()) * time.Millisecond
+	ctx, cFunc := context.WithTimeout(ctx, timeout)
+	defer cFunc()
+
+	req, err := http.NewRequestWithContext(ctx, hc.Method, hc.CompareWith, bytes.NewReader([]byte(hc.Body)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	rootCAs, err := m.caPool(site)
+	if err != nil {
+		return fmt.Errorf("loading CA certificates: %w", err)
+	}
+	tr := &http.Transport{
+		DialContext: m.dialer(site, timeout).DialContext,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: !m.verifyCert(hc.VerifyCert),
+			RootCAs:            rootCAs,
+		},
+		DisableKeepAlives: true,
+	}
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		return fmt.Errorf("requesting stable endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+	stable, _, err := readBody(resp, nil)
+	if err != nil {
+		return fmt.Errorf("reading stable response: %w", err)
+	}
+
+	d := diffBodies(body, stable)
+	zLog.Info("canary",
+		zap.String("uri", site.Server),
+		zap.String("compareWith", hc.CompareWith),
+		zap.Int("status", status),
+		zap.Int("stableStatus", resp.StatusCode),
+		zap.Int("bytes", len(body)),
+		zap.Int("stableBytes", len(stable)),
+		zap.Int("lines", d.lines),
+		zap.Int("differing", d.differing),
+		zap.Int("firstDifferingLine", d.firstLine))
+
+	if status != resp.StatusCode {
+		return fmt.Errorf("canary status %d differs from stable status %d", status, resp.StatusCode)
+	}
+	if d.ratio() > hc.CompareTolerance {
+		return fmt.Errorf("canary body differs from stable in %d of %d lines (first at line %d), beyond tolerance %.2f",
+			d.differing, d.lines, d.firstLine, hc.CompareTolerance)
+	}
+	return nil
+}
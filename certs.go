@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// loadCAPool answers the system certificate pool, extended with the PEM
+// certificates in the given file, and in the `.pem` and `.crt` files of
+// the given directory.  It answers nil if neither is specified, so that
+// the system pool is used as such.
+func loadCAPool(file, dir string) (*x509.CertPool, error) {
+	if file == "" && dir == "" {
+		return nil, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	var files []string
+	if file != "" {
+		files = append(files, file)
+	}
+	if dir != "" {
+		for _, pat := range []string{"*.pem", "*.crt"} {
+			names, err := filepath.Glob(filepath.Join(dir, pat))
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, names...)
+		}
+	}
+
+	for _, f := range files {
+		buf, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(buf) {
+			return nil, fmt.Errorf("no certificates found in %s", f)
+		}
+	}
+	return pool, nil
+}
+
+// caPoolKey answers the key of the CA pool of the given site's HTTP
+// checks; empty if it uses the global one.
+func caPoolKey(site *Site) string {
+	hc := &site.HTTPConfig
+	if hc.CACertFile == "" && hc.CACertDir == "" {
+		return ""
+	}
+	return hc.CACertFile + "|" + hc.CACertDir
+}
+
+// loadCAPools loads the CA pools of all the sites that specify their
+// own, once.
+func (m *Monitor) loadCAPools() error {
+	m.caPools = make(map[string]*x509.CertPool)
+	for i := range m.conf.Sites {
+		site := &m.conf.Sites[i]
+		k := caPoolKey(site)
+		if k == "" || m.caPools[k] != nil {
+			continue
+		}
+
+		pool, err := loadCAPool(site.HTTPConfig.CACertFile, site.HTTPConfig.CACertDir)
+		if err != nil {
+			return fmt.Errorf("site '%s' : CA certificates : %w", site.Server, err)
+		}
+		m.caPools[k] = pool
+	}
+	return nil
+}
+
+// caPool answers the pool of trusted CAs for the given site's HTTP
+// checks: its own, if specified; else, the global one.  A pool not
+// loaded already is loaded now, and retained.
+func (m *Monitor) caPool(site *Site) (*x509.CertPool, error) {
+	k := caPoolKey(site)
+	if k == "" {
+		return m.rootCAs, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if pool := m.caPools[k]; pool != nil {
+		return pool, nil
+	}
+	pool, err := loadCAPool(site.HTTPConfig.CACertFile, site.HTTPConfig.CACertDir)
+	if err != nil {
+		return nil, err
+	}
+	if m.caPools == nil {
+		m.caPools = make(map[string]*x509.CertPool)
+	}
+	m.caPools[k] = pool
+	return pool, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA answers a TLS server whose certificate is signed by a test CA,
+// and a file holding the certificate of the CA, in PEM.
+func testCA(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+	newKey := func() *ecdsa.PrivateKey {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+
+	caKey := newKey()
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := newKey()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	file := filepath.Join(t.TempDir(), "ca.pem")
+	buf := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})
+	if err := os.WriteFile(file, buf, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return srv, file
+}
+
+func TestSiteCACertFile(t *testing.T) {
+	srv, caFile := testCA(t)
+
+	t.Run("untrusted", func(t *testing.T) {
+		m, _ := newTestMonitor(&Config{DefaultVerifyCert: true})
+		_, err := m.probeHTTP(context.Background(), httpSite(t, srv))
+		if err == nil || !strings.Contains(err.Error(), "class: "+httpErrTLS) {
+			t.Fatalf("expected a TLS failure; got %v", err)
+		}
+	})
+
+	t.Run("trusted", func(t *testing.T) {
+		site := httpSite(t, srv)
+		site.HTTPConfig.CACertFile = caFile
+		m, _ := newTestMonitor(&Config{DefaultVerifyCert: true, Sites: []Site{*site}})
+		if err := m.loadCAPools(); err != nil {
+			t.Fatal(err)
+		}
+		// The pool is loaded at startup, and not on each check.
+		if err := os.Remove(caFile); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			if _, err := m.probeHTTP(context.Background(), site); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
+)
+
+// checkAMQP dials the given AMQP broker, as per the given specification,
+// and opens a channel on it.  Optionally, it verifies that a queue
+// exists, by declaring it passively.
+func (m *Monitor) checkAMQP(ctx context.Context, site *Site) error {
+	// Connection setup.
+	ac := &site.AMQPConfig
+	timeout := time.Duration(site.TimeoutMillis) * time.Millisecond
+	scheme := "amqp"
+	if ac.TLS {
+		scheme = "amqps"
+	}
+	u := &url.URL{
+		Scheme: scheme,
+		User:   url.UserPassword(ac.Username, ac.Password),
+		Host:   net.JoinHostPort(site.Server, strconv.Itoa(ac.Port)),
+	}
+	if ac.VHost != "" {
+		// The virtual host may itself contain slashes.
+		u.Path = "/" + ac.VHost
+		u.RawPath = "/" + url.PathEscape(ac.VHost)
+	}
+	conf := amqp.Config{
+		Dial:            amqp.DefaultDial(timeout),
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: !m.verifyCert(ac.VerifyCert)},
+		Properties:      amqp.Table{"connection_name": "HeartBeat"},
+	}
+
+	tb := time.Now()
+	conn, err := amqp.DialConfig(u.String(), conf)
+	if err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: connect to broker, err: %s", err.Error())
+	}
+	defer conn.Close()
+	tConnect := time.Since(tb).Milliseconds()
+	tracePhase(ctx, "connect", tb, time.Now())
+	// The client does not take a context; closing the connection
+	// unblocks a pending operation.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: open channel, err: %s", err.Error())
+	}
+	defer ch.Close()
+
+	// Verify that the queue exists, if asked for.
+	if ac.Queue != "" {
+		q, err := ch.QueueDeclarePassive(ac.Queue, false, false, false, false, nil)
+		if err != nil {
+			zLog.Error(site.Protocol,
+				zap.String("queue", ac.Queue),
+				zap.String("error", err.Error()))
+			return fmt.Errorf("action: declare queue passively, err: %s", err.Error())
+		}
+		zLog.Info(site.Protocol,
+			zap.String("queue", q.Name),
+			zap.Int("messages", q.Messages),
+			zap.Int("consumers", q.Consumers))
+	}
+
+	zLog.Info(site.Protocol,
+		zap.String("server", site.Server),
+		zap.Int64("connect", tConnect),
+		zap.Int64("total", time.Since(tb).Milliseconds()))
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gocql/gocql"
+	"go.uber.org/zap"
+)
+
+// checkCassandra connects to the given Cassandra or ScyllaDB cluster, as
+// per the given specification.  Optionally, it runs a lightweight query.
+func (m *Monitor) checkCassandra(ctx context.Context, site *Site) error {
+	// Connection setup.
+	cc := &site.CassandraConfig
+	hosts := cc.Hosts
+	if len(hosts) == 0 {
+		hosts = []string{site.Server}
+	}
+	timeout := time.Duration(site.TimeoutMillis) * time.Millisecond
+	cluster := gocql.NewCluster(hosts...)
+	if cc.Port != 0 {
+		cluster.Port = cc.Port
+	}
+	cluster.Keyspace = cc.Keyspace
+	cluster.ConnectTimeout = timeout
+	cluster.Timeout = timeout
+	cluster.NumConns = 1
+	cluster.DisableInitialHostLookup = true
+	if cc.Consistency != "" {
+		// Already validated.
+		cluster.Consistency, _ = gocql.ParseConsistencyWrapper(cc.Consistency)
+	}
+	if cc.Username != "" {
+		cluster.Authenticator = gocql.PasswordAuthenticator{
+			Username: cc.Username,
+			Password: cc.Password,
+		}
+	}
+
+	tb := time.Now()
+	sess, err := cluster.CreateSession()
+	if err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("uri", site.Server),
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: connect to cluster, err: %s", err.Error())
+	}
+	defer sess.Close()
+	tConnect := time.Since(tb).Milliseconds()
+	tracePhase(ctx, "connect", tb, time.Now())
+
+	if !cc.Query {
+		zLog.Info(site.Protocol,
+			zap.String("uri", site.Server),
+			zap.Int64("connect", tConnect))
+		return nil
+	}
+
+	// Run the query, noting the coordinator that served it.
+	tq := time.Now()
+	var now gocql.UUID
+	iter := sess.Query(`SELECT now() FROM system.local`).WithContext(ctx).Iter()
+	iter.Scan(&now)
+	coordinator := ""
+	if h := iter.Host(); h != nil {
+		coordinator = h.ConnectAddressAndPort()
+	}
+	if err = iter.Close(); err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("uri", site.Server),
+			zap.String("coordinator", coordinator),
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: query cluster, err: %s", err.Error())
+	}
+	tQuery := time.Since(tq).Milliseconds()
+	tracePhase(ctx, "query", tq, time.Now())
+
+	zLog.Info(site.Protocol,
+		zap.String("uri", site.Server),
+		zap.String("coordinator", coordinator),
+		zap.Int64("connect", tConnect),
+		zap.Int64("query", tQuery),
+		zap.Int64("total", time.Since(tb).Milliseconds()))
+	return nil
+}
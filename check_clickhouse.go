@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// checkClickHouse makes a connection request to the given server, as per
+// the given specification.
+func (m *Monitor) checkClickHouse(ctx context.Context, site *Site) error {
+	// Connection setup.
+	cc := &site.ClickHouseConfig
+	opts := &clickhouse.Options{
+		Addr: []string{net.JoinHostPort(site.Server, strconv.Itoa(cc.Port))},
+		Auth: clickhouse.Auth{
+			Database: cc.Database,
+			Username: cc.Username,
+			Password: cc.Password,
+		},
+		DialTimeout: time.Duration(site.TimeoutMillis) * time.Millisecond,
+	}
+	if m.sourceAddress(site) != "" {
+		d := m.dialer(site, opts.DialTimeout)
+		opts.DialContext = func(ctx context.Context, addr string) (net.Conn, error) {
+			return d.DialContext(ctx, "tcp", addr)
+		}
+	}
+	db := sqlx.NewDb(clickhouse.OpenDB(opts), "clickhouse")
+	defer db.Close()
+
+	// Execute query, so that an actual connection is made.
+	q := `SELECT 1`
+	tb := time.Now()
+	phase, class, err := queryDB(ctx, site, db, q, newDBLimits(site, 0, 0, 0))
+	if err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("phase", phase),
+			zap.String("class", class),
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: query database, phase: %s, class: %s, err: %s", phase, class, err.Error())
+	}
+	te := time.Now()
+
+	zLog.Info(site.Protocol,
+		zap.String("server", site.Server),
+		zap.Int64("total", te.Sub(tb).Milliseconds()))
+	return nil
+}
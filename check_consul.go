@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// consulServiceEntry is the portion of an entry answered by Consul's
+// service health API that is of interest.
+type consulServiceEntry struct {
+	Checks []struct {
+		Status string `json:"Status"`
+	} `json:"Checks"`
+}
+
+// checkConsul queries the health of the configured service from the
+// given Consul agent, and verifies that enough instances are passing.
+func (m *Monitor) checkConsul(ctx context.Context, site *Site) error {
+	cc := &site.ConsulConfig
+	scheme := "http"
+	if cc.TLS {
+		scheme = "https"
+	}
+	query := url.Values{}
+	if cc.Datacenter != "" {
+		query.Add("dc", cc.Datacenter)
+	}
+	u := &url.URL{
+		Scheme:   scheme,
+		Host:     net.JoinHostPort(site.Server, strconv.Itoa(cc.Port)),
+		Path:     "/v1/health/service/" + cc.Service,
+		RawPath:  "/v1/health/service/" + url.PathEscape(cc.Service),
+		RawQuery: query.Encode(),
+	}
+
+	timeout := time.Duration(site.TimeoutMillis) * time.Millisecond
+	ctx, cFunc := context.WithTimeout(ctx, timeout)
+	defer cFunc()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("action: construct request, err: %s", err.Error())
+	}
+	if cc.Token != "" {
+		req.Header.Set("X-Consul-Token", cc.Token)
+	}
+
+	rootCAs, err := m.caPool(site)
+	if err != nil {
+		return fmt.Errorf("action: load CA certificates, err: %s", err.Error())
+	}
+	tr := &http.Transport{
+		DialContext: m.dialer(site, timeout).DialContext,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: !m.verifyCert(cc.VerifyCert),
+			RootCAs:            rootCAs,
+		},
+		DisableKeepAlives: true,
+	}
+	cl := &http.Client{Transport: tr, Timeout: timeout}
+
+	tb := time.Now()
+	resp, err := cl.Do(req)
+	if err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: query service health, err: %s", err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		zLog.Error(site.Protocol,
+			zap.Int("status", resp.StatusCode),
+			zap.String("error", resp.Status))
+		return fmt.Errorf("action: query service health, status: %d : %s", resp.StatusCode, resp.Status)
+	}
+
+	var entries []consulServiceEntry
+	if err = json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return fmt.Errorf("action: decode service health, err: %s", err.Error())
+	}
+	tracePhase(ctx, "query", tb, time.Now())
+
+	// An instance is passing only if all its checks are.
+	passing := 0
+	for _, e := range entries {
+		ok := true
+		for _, c := range e.Checks {
+			if c.Status != "passing" {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			passing++
+		}
+	}
+
+	zLog.Info(site.Protocol,
+		zap.String("server", site.Server),
+		zap.String("service", cc.Service),
+		zap.Int("passing", passing),
+		zap.Int("total", len(entries)),
+		zap.Int64("ms", time.Since(tb).Milliseconds()))
+
+	minPassing := cc.MinPassing
+	if minPassing == 0 {
+		minPassing = 1
+	}
+	if passing < minPassing {
+		return fmt.Errorf("service '%s' : %d of %d instances passing; at least %d required", cc.Service, passing, len(entries), minPassing)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestConsulServiceEscaped(t *testing.T) {
+	const path = "/v1/health/service/web%2Fapi%20v2"
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.RequestURI != path {
+			t.Errorf("requested %s, expected %s", r.RequestURI, path)
+		}
+		w.Write([]byte(`[{"Checks": [{"Status": "passing"}]}]`))
+	}))
+	t.Cleanup(srv.Close)
+	host, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+
+	verify := false
+	site := &Site{Protocol: "consul", Server: host, TimeoutMillis: 2000, ConsulConfig: ConsulConfig{
+		Port:       p,
+		TLS:        true,
+		VerifyCert: &verify,
+		Service:    "web/api v2",
+	}}
+	m := &Monitor{conf: &Config{}}
+	if err := m.checkConsul(context.Background(), site); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConsulTrustsConfiguredCAs(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"Checks": [{"Status": "passing"}]}]`))
+	}))
+	t.Cleanup(srv.Close)
+	host, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+
+	site := &Site{Protocol: "consul", Server: host, TimeoutMillis: 2000, ConsulConfig: ConsulConfig{Port: p, TLS: true, Service: "web"}}
+	m := &Monitor{conf: &Config{DefaultVerifyCert: true}}
+	if err := m.checkConsul(context.Background(), site); err == nil {
+		t.Fatal("expected an untrusted certificate to fail")
+	}
+	m.rootCAs = x509.NewCertPool()
+	m.rootCAs.AddCert(srv.Certificate())
+	if err := m.checkConsul(context.Background(), site); err != nil {
+		t.Fatal(err)
+	}
+}
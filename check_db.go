@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// Classes of database errors.
+const (
+	dbErrNetwork = "network"
+	dbErrAuth    = "auth"
+	dbErrQuery   = "query"
+)
+
+// classifyDBError answers the class of the given database driver error.
+// Only network errors are transient, and are worth retrying.
+func classifyDBError(err error) string {
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		switch myErr.Number {
+		case 1044, 1045, 1698: // access denied
+			return dbErrAuth
+		default:
+			return dbErrQuery
+		}
+	}
+	var msErr mssql.Error
+	if errors.As(err, &msErr) {
+		switch msErr.Number {
+		case 18452, 18456: // login failed
+			return dbErrAuth
+		default:
+			return dbErrQuery
+		}
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		case "28": // invalid authorization specification
+			return dbErrAuth
+		default:
+			return dbErrQuery
+		}
+	}
+	var chErr *clickhouse.Exception
+	if errors.As(err, &chErr) {
+		switch chErr.Code {
+		case 192, 516: // unknown user, authentication failed
+			return dbErrAuth
+		default:
+			return dbErrQuery
+		}
+	}
+	// SQL Server's driver does not wrap login failures.
+	if strings.HasPrefix(err.Error(), "Login error") {
+		return dbErrAuth
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr),
+		errors.Is(err, driver.ErrBadConn),
+		errors.Is(err, mysql.ErrInvalidConn),
+		errors.Is(err, context.DeadlineExceeded):
+		return dbErrNetwork
+	}
+
+	return dbErrQuery
+}
+
+// Phases of a database probe.
+const (
+	dbPhaseConnect = "connect"
+	dbPhaseQuery   = "query"
+)
+
+// dbLimits bounds a database probe.  The connection and the query have
+// separate deadlines, so that a slow connection is told apart from a
+// slow query.
+type dbLimits struct {
+	retries int // network errors only
+	connect time.Duration
+	query   time.Duration
+}
+
+// newDBLimits answers the limits for probing the given site.  Unspecified
+// timeouts default to the site's timeout.
+func newDBLimits(site *Site, retries int, connectMillis, queryMillis int64) dbLimits {
+	if connectMillis <= 0 {
+		connectMillis = site.TimeoutMillis
+	}
+	if queryMillis <= 0 {
+		queryMillis = site.TimeoutMillis
+	}
+	return dbLimits{
+		retries: retries,
+		connect: time.Duration(connectMillis) * time.Millisecond,
+		query:   time.Duration(queryMillis) * time.Millisecond,
+	}
+}
+
+// queryDB connects to the database, and runs the given probe query,
+// retrying up to the given number of times in case of network errors.
+// It answers the phase that failed, and the class of the final error, if
+// any.
+func queryDB(ctx context.Context, site *Site, db *sqlx.DB, q string, lim dbLimits) (string, string, error) {
+	for attempt := 0; ; attempt++ {
+		phase, err := probeDB(ctx, db, q, lim)
+		if err == nil {
+			return "", "", nil
+		}
+
+		class := classifyDBError(err)
+		if class != dbErrNetwork || attempt >= lim.retries || ctx.Err() != nil {
+			return phase, class, err
+		}
+		zLog.Warn(site.Protocol,
+			zap.String("server", site.Server),
+			zap.Int("attempt", attempt+1),
+			zap.String("phase", phase),
+			zap.String("error", err.Error()))
+	}
+}
+
+// probeDB makes a single connection to the database, and runs the given
+// query on it, each within its own deadline, and traced as a phase of the
+// check.  It answers the phase that failed, if any.
+func probeDB(ctx context.Context, db *sqlx.DB, q string, lim dbLimits) (string, error) {
+	tb := time.Now()
+	cctx, cFunc := context.WithTimeout(ctx, lim.connect)
+	conn, err := db.Conn(cctx)
+	cFunc()
+	if err != nil {
+		return dbPhaseConnect, err
+	}
+	defer conn.Close()
+	tc := time.Now()
+	tracePhase(ctx, dbPhaseConnect, tb, tc)
+
+	var name string
+	qctx, cFunc := context.WithTimeout(ctx, lim.query)
+	defer cFunc()
+	if err = conn.QueryRowContext(qctx, q).Scan(&name); err != nil {
+		return dbPhaseQuery, err
+	}
+	tracePhase(ctx, dbPhaseQuery, tc, time.Now())
+	return "", nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+func TestClassifyDBError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"mysql access denied", &mysql.MySQLError{Number: 1045}, dbErrAuth},
+		{"mysql unknown table", &mysql.MySQLError{Number: 1146}, dbErrQuery},
+		{"mysql invalid connection", mysql.ErrInvalidConn, dbErrNetwork},
+		{"sqlserver login failed", mssql.Error{Number: 18456}, dbErrAuth},
+		{"sqlserver invalid object", mssql.Error{Number: 208}, dbErrQuery},
+		{"sqlserver login error", errors.New("Login error: EOF"), dbErrAuth},
+		{"postgres bad password", &pq.Error{Code: "28P01"}, dbErrAuth},
+		{"postgres undefined table", &pq.Error{Code: "42P01"}, dbErrQuery},
+		{"clickhouse authentication failed", &clickhouse.Exception{Code: 516}, dbErrAuth},
+		{"clickhouse unknown table", &clickhouse.Exception{Code: 60}, dbErrQuery},
+		{"bad connection", driver.ErrBadConn, dbErrNetwork},
+		{"deadline", context.DeadlineExceeded, dbErrNetwork},
+		{"network", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, dbErrNetwork},
+		{"wrapped", fmt.Errorf("pinging: %w", &mysql.MySQLError{Number: 1045}), dbErrAuth},
+		{"other", errors.New("syntax error"), dbErrQuery},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyDBError(tt.err); got != tt.want {
+				t.Fatalf("class %q, expected %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// dockerContainerState is the portion of the container details answered
+// by the Docker Engine API that is of interest.
+type dockerContainerState struct {
+	State struct {
+		Status    string    `json:"Status"`
+		Running   bool      `json:"Running"`
+		StartedAt time.Time `json:"StartedAt"`
+	} `json:"State"`
+}
+
+// checkDocker pings the Docker daemon, as per the given specification,
+// and verifies that the configured container, if any, is running.
+func (m *Monitor) checkDocker(ctx context.Context, site *Site) error {
+	dc := &site.DockerConfig
+	timeout := time.Duration(site.TimeoutMillis) * time.Millisecond
+
+	// The daemon is reached either through its unix socket, or over TCP.
+	tr := &http.Transport{DisableKeepAlives: true}
+	u := &url.URL{Scheme: "http", Host: "docker"}
+	if dc.Socket != "" {
+		tr.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", dc.Socket)
+		}
+	} else {
+		u.Host = net.JoinHostPort(site.Server, strconv.Itoa(dc.Port))
+		if dc.TLS {
+			u.Scheme = "https"
+			tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: !m.verifyCert(dc.VerifyCert)}
+		}
+	}
+	client := &http.Client{Transport: tr, Timeout: timeout}
+
+	tb := time.Now()
+	u.Path = "/_ping"
+	if _, err := dockerGet(ctx, client, u.String()); err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("uri", site.Server),
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: ping daemon, err: %s", err.Error())
+	}
+	tPing := time.Since(tb).Milliseconds()
+	ti := time.Now()
+	tracePhase(ctx, "ping", tb, ti)
+	if dc.Container == "" {
+		zLog.Info(site.Protocol,
+			zap.String("uri", site.Server),
+			zap.Int64("ping", tPing))
+		return nil
+	}
+
+	u.Path = "/containers/" + dc.Container + "/json"
+	buf, err := dockerGet(ctx, client, u.String())
+	if err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("uri", site.Server),
+			zap.String("container", dc.Container),
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: inspect container '%s', err: %s", dc.Container, err.Error())
+	}
+	tracePhase(ctx, "inspect", ti, time.Now())
+	var cs dockerContainerState
+	if err = json.Unmarshal(buf, &cs); err != nil {
+		return fmt.Errorf("action: decode container details, err: %s", err.Error())
+	}
+
+	var uptime time.Duration
+	if cs.State.Running {
+		uptime = m.since(cs.State.StartedAt).Truncate(time.Second)
+	}
+	zLog.Info(site.Protocol,
+		zap.String("uri", site.Server),
+		zap.String("container", dc.Container),
+		zap.String("status", cs.State.Status),
+		zap.Duration("uptime", uptime),
+		zap.Int64("ping", tPing),
+		zap.Int64("total", time.Since(tb).Milliseconds()))
+
+	if !cs.State.Running {
+		return fmt.Errorf("container '%s' not running : status : %s", dc.Container, cs.State.Status)
+	}
+	return nil
+}
+
+// dockerGet makes a GET request to the Docker Engine API, and answers
+// the (capped) body of a successful response.
+func dockerGet(ctx context.Context, client *http.Client, u string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	buf, err := io.ReadAll(io.LimitReader(resp.Body, MaxBodyBytes))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status: %d : %s", resp.StatusCode, resp.Status)
+	}
+	return buf, nil
+}
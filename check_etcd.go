@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+// checkEtcd connects to the given etcd cluster, as per the given
+// specification, and verifies the health of each of its members.  It
+// fails if any member is unhealthy, or if the cluster has lost quorum.
+func (m *Monitor) checkEtcd(ctx context.Context, site *Site) error {
+	// Connection setup.
+	ec := &site.EtcdConfig
+	timeout := time.Duration(site.TimeoutMillis) * time.Millisecond
+	endpoints := ec.Endpoints
+	if len(endpoints) == 0 {
+		endpoints = []string{net.JoinHostPort(site.Server, strconv.Itoa(ec.Port))}
+	}
+	conf := clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: timeout,
+		Username:    ec.Username,
+		Password:    ec.Password,
+		Logger:      zap.NewNop(),
+		Context:     ctx,
+	}
+	if ec.TLS {
+		conf.TLS = &tls.Config{InsecureSkipVerify: !m.verifyCert(ec.VerifyCert)}
+	}
+
+	tb := time.Now()
+	cl, err := clientv3.New(conf)
+	if err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: connect to cluster, err: %s", err.Error())
+	}
+	defer cl.Close()
+	tracePhase(ctx, "connect", tb, time.Now())
+
+	ctx, cFunc := context.WithTimeout(ctx, timeout)
+	defer cFunc()
+	members, err := cl.MemberList(ctx)
+	if err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: list members, err: %s", err.Error())
+	}
+
+	// Query the status of each member through its own client URL.
+	var leader uint64
+	healthy := 0
+	var unhealthy []string
+	for _, mem := range members.Members {
+		if len(mem.ClientURLs) == 0 {
+			unhealthy = append(unhealthy, mem.Name)
+			continue
+		}
+		st, err := cl.Status(ctx, mem.ClientURLs[0])
+		if err != nil || len(st.Errors) > 0 {
+			zLog.Error(site.Protocol,
+				zap.String("member", mem.Name),
+				zap.Any("error", etcdStatusError(err, st)))
+			unhealthy = append(unhealthy, mem.Name)
+			continue
+		}
+		healthy++
+		if st.Leader != 0 {
+			leader = st.Leader
+		}
+	}
+
+	total := len(members.Members)
+	zLog.Info(site.Protocol,
+		zap.String("server", site.Server),
+		zap.String("leader", strconv.FormatUint(leader, 16)),
+		zap.Int("healthy", healthy),
+		zap.Int("members", total),
+		zap.Int64("total", time.Since(tb).Milliseconds()))
+
+	switch {
+	case healthy <= total/2 || leader == 0:
+		return fmt.Errorf("cluster has lost quorum : %d of %d members healthy, leader: %x", healthy, total, leader)
+	case len(unhealthy) > 0:
+		return fmt.Errorf("unhealthy members : %v", unhealthy)
+	}
+	return nil
+}
+
+// etcdStatusError answers the given error if non-nil, or else the errors reported
+// in the given status.
+func etcdStatusError(err error, st *clientv3.StatusResponse) interface{} {
+	if err != nil {
+		return err.Error()
+	}
+	return st.Errors
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// checkGRPC checks a gRPC service, as per the given specification: by
+// calling the standard health service, or, if a method is specified, by
+// invoking that unary method.  A method that takes a request is resolved
+// through server reflection, or from a provided descriptor set.
+func (m *Monitor) checkGRPC(ctx context.Context, site *Site) error {
+	gc := &site.GRPCConfig
+	timeout := time.Duration(site.TimeoutMillis) * time.Millisecond
+	ctx, cFunc := context.WithTimeout(ctx, timeout)
+	defer cFunc()
+
+	creds := insecure.NewCredentials()
+	if gc.TLS {
+		creds = credentials.NewTLS(&tls.Config{InsecureSkipVerify: !m.verifyCert(gc.VerifyCert)})
+	}
+	addr := net.JoinHostPort(site.Server, strconv.Itoa(gc.Port))
+	conn, err := grpc.NewClient(addr,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return m.dialer(site, timeout).DialContext(ctx, "tcp", addr)
+		}))
+	if err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("uri", addr),
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: connect, err: %s", err.Error())
+	}
+	defer conn.Close()
+
+	tb := time.Now()
+	if gc.Method == "" {
+		resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: gc.Service})
+		tracePhase(ctx, "call", tb, time.Now())
+		zLog.Info(site.Protocol,
+			zap.String("uri", addr),
+			zap.String("method", "grpc.health.v1.Health/Check"),
+			zap.String("code", status.Code(err).String()),
+			zap.Int64("total", time.Since(tb).Milliseconds()))
+		if err != nil {
+			return fmt.Errorf("action: check health, err: %s", err.Error())
+		}
+		if st := resp.GetStatus(); st != healthpb.HealthCheckResponse_SERVING {
+			return fmt.Errorf("service '%s' not serving : status : %s", gc.Service, st)
+		}
+		return nil
+	}
+
+	// An empty request serialises identically for every message type, and
+	// the response is not inspected; so, neither needs a descriptor.
+	var in, out proto.Message = &emptypb.Empty{}, &emptypb.Empty{}
+	if gc.Request != "" {
+		md, err := grpcMethod(ctx, conn, gc)
+		if err != nil {
+			zLog.Error(site.Protocol,
+				zap.String("uri", addr),
+				zap.String("method", gc.Method),
+				zap.String("error", err.Error()))
+			return fmt.Errorf("action: resolve method, err: %s", err.Error())
+		}
+		req := dynamicpb.NewMessage(md.Input())
+		if err = protojson.Unmarshal([]byte(gc.Request), req); err != nil {
+			return fmt.Errorf("%w: invalid request for '%s': %s", ErrConfig, gc.Method, err.Error())
+		}
+		in, out = req, dynamicpb.NewMessage(md.Output())
+	}
+
+	tc := time.Now()
+	err = conn.Invoke(ctx, gc.Method, in, out)
+	tracePhase(ctx, "call", tc, time.Now())
+	zLog.Info(site.Protocol,
+		zap.String("uri", addr),
+		zap.String("method", gc.Method),
+		zap.String("code", status.Code(err).String()),
+		zap.Int64("total", time.Since(tb).Milliseconds()))
+	if err != nil {
+		return fmt.Errorf("action: invoke '%s', code: %s, err: %s", gc.Method, status.Code(err), status.Convert(err).Message())
+	}
+	return nil
+}
+
+// splitGRPCMethod splits a full method path, `/package.Service/Method`,
+// into its service and method names.
+func splitGRPCMethod(path string) (string, string, bool) {
+	svc, method, ok := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	return svc, method, ok && svc != "" && method != "" && !strings.Contains(method, "/")
+}
+
+// grpcMethod answers the descriptor of the configured method, from the
+// descriptor set file, if specified, or through server reflection.
+func grpcMethod(ctx context.Context, conn *grpc.ClientConn, gc *GRPCConfig) (protoreflect.MethodDescriptor, error) {
+	svc, method, _ := splitGRPCMethod(gc.Method)
+
+	var fds *descriptorpb.FileDescriptorSet
+	var err error
+	if gc.DescriptorSetFile != "" {
+		fds, err = readDescriptorSet(gc.DescriptorSetFile)
+	} else {
+		fds, err = reflectDescriptors(ctx, conn, svc)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := protodesc.NewFiles(fds)
+	if err != nil {
+		return nil, err
+	}
+	d, err := files.FindDescriptorByName(protoreflect.FullName(svc))
+	if err != nil {
+		return nil, err
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("not a service: %s", svc)
+	}
+	md := sd.Methods().ByName(protoreflect.Name(method))
+	if md == nil {
+		return nil, fmt.Errorf("no such method: %s", gc.Method)
+	}
+	if md.IsStreamingClient() || md.IsStreamingServer() {
+		return nil, fmt.Errorf("not a unary method: %s", gc.Method)
+	}
+	return md, nil
+}
+
+// readDescriptorSet reads a binary `FileDescriptorSet`, as produced by
+// `protoc --include_imports --descriptor_set_out`.
+func readDescriptorSet(name string) (*descriptorpb.FileDescriptorSet, error) {
+	buf, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	fds := &descriptorpb.FileDescriptorSet{}
+	if err = proto.Unmarshal(buf, fds); err != nil {
+		return nil, fmt.Errorf("decoding descriptor set: %w", err)
+	}
+	return fds, nil
+}
+
+// reflectDescriptors fetches, through server reflection, the file that
+// defines the given symbol, together with its transitive dependencies.
+func reflectDescriptors(ctx context.Context, conn *grpc.ClientConn, symbol string) (*descriptorpb.FileDescriptorSet, error) {
+	stream, err := reflectpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.CloseSend()
+
+	fetch := func(req *reflectpb.ServerReflectionRequest) ([][]byte, error) {
+		if err := stream.Send(req); err != nil {
+			return nil, err
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		if e := resp.GetErrorResponse(); e != nil {
+			return nil, fmt.Errorf("reflection: %s", e.GetErrorMessage())
+		}
+		return resp.GetFileDescriptorResponse().GetFileDescriptorProto(), nil
+	}
+
+	fds := &descriptorpb.FileDescriptorSet{}
+	seen := make(map[string]bool)
+	var pending []string
+	add := func(bufs [][]byte) error {
+		for _, buf := range bufs {
+			fd := &descriptorpb.FileDescriptorProto{}
+			if err := proto.Unmarshal(buf, fd); err != nil {
+				return err
+			}
+			if seen[fd.GetName()] {
+				continue
+			}
+			seen[fd.GetName()] = true
+			fds.File = append(fds.File, fd)
+			pending = append(pending, fd.GetDependency()...)
+		}
+		return nil
+	}
+
+	bufs, err := fetch(&reflectpb.ServerReflectionRequest{
+		MessageRequest: &reflectpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: symbol},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = add(bufs); err != nil {
+		return nil, err
+	}
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		if seen[name] {
+			continue
+		}
+		bufs, err := fetch(&reflectpb.ServerReflectionRequest{
+			MessageRequest: &reflectpb.ServerReflectionRequest_FileByFilename{FileByFilename: name},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err = add(bufs); err != nil {
+			return nil, err
+		}
+	}
+	return fds, nil
+}
@@ -2,13 +2,29 @@ package main
 
 import (
 	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptrace"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"go.uber.org/zap"
+	"golang.org/x/net/proxy"
 )
 
 // checkHTTP makes a  HTTP(S) request to the given server, as per the
@@ -71,9 +87,224 @@ func (m *Monitor) checkHTTP(site *Site) error {
 	return nil
 }
 
-// checkHTTPx makes a  HTTP(S) request to the given server, as per the
-// given specification.
-func (m *Monitor) checkHTTPx(site *Site) error {
+// checkHTTPSite checks each of the URLs of the given site, if several
+// are specified, and answers their failures, if any, as `urlErrors`.
+// Otherwise, it checks the site's single URL.
+func (m *Monitor) checkHTTPSite(ctx context.Context, site *Site) error {
+	if len(site.HTTPConfig.URLs) == 0 {
+		return m.checkHTTPRetrying(ctx, site)
+	}
+
+	var errs []error
+	for _, u := range site.HTTPConfig.URLs {
+		s := *site
+		s.HTTPConfig.URL = u
+		s.HTTPConfig.URLs = nil
+		if s.Name != "" {
+			s.Name += "/" + u // keeps per-URL state apart
+		}
+		if err := m.checkHTTPRetrying(ctx, &s); err != nil {
+			errs = append(errs, fmt.Errorf("/%s : %w", u, err))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return urlErrors(errs)
+}
+
+// urlErrors holds the failures of the several URLs of a site, each of
+// which is alerted on separately.
+type urlErrors []error
+
+func (e urlErrors) Error() string {
+	return errors.Join(e...).Error()
+}
+
+func (e urlErrors) Unwrap() []error {
+	return e
+}
+
+// checkHTTPx probes the given site as many times as configured, and
+// makes the up/down and threshold decisions on the aggregate of the
+// probes.
+func (m *Monitor) checkHTTPx(ctx context.Context, site *Site) error {
+	n := max(site.HTTPConfig.ProbesPerCheck, 1)
+	agg := site.HTTPConfig.Aggregation
+	if agg == "" {
+		agg = aggMedian
+	}
+
+	var probes []*httpProbe
+	var lastErr error
+	for i := 0; i < n; i++ {
+		p, err := m.probeHTTP(ctx, site)
+		var rErr *retryStatusError
+		switch {
+		case errors.As(err, &rErr):
+			return err
+		case err != nil:
+			lastErr = err
+		case p == nil:
+			return nil // planned maintenance
+		default:
+			probes = append(probes, p)
+		}
+	}
+
+	// One success suffices for `min`; else, most of the probes must
+	// succeed.
+	need := n/2 + 1
+	if agg == aggMin {
+		need = 1
+	}
+	if len(probes) < need {
+		if n == 1 {
+			return lastErr
+		}
+		return fmt.Errorf("%d of %d probes failed : %w", n-len(probes), n, lastErr)
+	}
+
+	pick := func(f func(p *httpProbe) int64) int64 {
+		vs := make([]int64, len(probes))
+		for i, p := range probes {
+			vs[i] = f(p)
+		}
+		return aggregate(vs, agg)
+	}
+	tResolve := pick(func(p *httpProbe) int64 { return p.resolve })
+	tConnection := pick(func(p *httpProbe) int64 { return p.connection })
+	tTLS := pick(func(p *httpProbe) int64 { return p.tls })
+	tProcessing := pick(func(p *httpProbe) int64 { return p.processing })
+	ttfb := pick(func(p *httpProbe) int64 { return p.ttfb })
+	tTotal := pick(func(p *httpProbe) int64 { return p.total })
+	last := probes[len(probes)-1]
+	resp, body := last.resp, last.body
+
+	var tTotalEMA float64
+	m.withState(site, func(st *siteState) {
+		tTotalEMA = st.recordEMA(float64(tTotal), m.conf.emaAlpha())
+	})
+	zLog.Info(site.Protocol,
+		zap.String("uri", site.Server),
+		zap.String("url", site.HTTPConfig.URL),
+		zap.Int("probes", len(probes)),
+		zap.Bool("reused", last.reused),
+		zap.Int64("resolve", tResolve),
+		zap.Int64("connect", tConnection),
+		zap.Int64("tls", tTLS),
+		zap.Int64("processing", tProcessing),
+		zap.Int64("serverTotal", tConnection+tTLS+tProcessing),
+		zap.Int64("ttfb", ttfb),
+		zap.Int64("total", tTotal),
+		zap.Float64("totalEma", tTotalEMA))
+	timings := []Timing{
+		{"resolve", tResolve},
+		{"connect", tConnection},
+		{"tls", tTLS},
+		{"processing", tProcessing},
+		{"ttfb", ttfb},
+		{"total", tTotal},
+	}
+	if limit := m.resolverTimeoutMillis(site); tResolve >= limit {
+		sErr := fmt.Errorf("DNS resolution time limit (%d) exceeded: %d ms", limit, tResolve)
+		// When DNS and service outcomes are combined, the check as a
+		// whole reports it.
+		if n := notesOf(ctx); n != nil && m.conf.CombineDNSAlerts {
+			n.noteDNS(sErr, timings)
+		} else {
+			m.alert(site, "dns", sErr, timings...)
+		}
+	}
+	if (tConnection + tTLS) >= int64(site.ConnectionTimeoutMillis) {
+		sErr := fmt.Errorf("connection + TLS time limit (%d) exceeded: %d ms", site.ConnectionTimeoutMillis, tConnection+tTLS)
+		m.alert(site, "connection + TLS", sErr, timings...)
+	}
+	if tProcessing >= site.TimeoutMillis {
+		sErr := fmt.Errorf("processing time limit (%d) exceeded: %d ms", site.TimeoutMillis, tProcessing)
+		m.alert(site, site.Protocol, sErr, timings...)
+	}
+	if hc := &site.HTTPConfig; hc.LatencyWindowSize > 0 && hc.P95ThresholdMillis > 0 {
+		var p95 int64
+		var full bool
+		m.withState(site, func(st *siteState) {
+			p95, full = st.recordLatency(tTotal, hc.LatencyWindowSize)
+		})
+		if full && p95 > hc.P95ThresholdMillis {
+			sErr := fmt.Errorf("p95 latency over last %d checks (%d) exceeded: %d ms", hc.LatencyWindowSize, hc.P95ThresholdMillis, p95)
+			m.alert(site, "latency", sErr)
+		}
+	}
+	if hc := &site.HTTPConfig; hc.AnomalyStdDevThreshold > 0 {
+		minSamples := hc.AnomalyMinSamples
+		if minSamples <= 0 {
+			minSamples = DefAnomalyMinSamples
+		}
+		var mean, sd float64
+		var anomalous bool
+		m.withState(site, func(st *siteState) {
+			mean, sd, anomalous = st.recordAnomaly(float64(tTotal), m.conf.emaAlpha(), hc.AnomalyStdDevThreshold, minSamples)
+		})
+		if anomalous {
+			sErr := fmt.Errorf("total latency %d ms exceeds rolling mean (%.0f ms) by more than %.1f standard deviations (%.0f ms)", tTotal, mean, hc.AnomalyStdDevThreshold, sd)
+			m.alert(site, "latency", sErr, timings...)
+		}
+	}
+	if site.HTTPConfig.CompareWith != "" {
+		if sErr := m.compareCanary(ctx, site, resp.StatusCode, body); sErr != nil {
+			m.alert(site, "canary", sErr)
+		}
+	}
+	if site.HTTPConfig.TrackContentHash {
+		if sErr := m.checkContentHash(site, body); sErr != nil {
+			m.alert(site, "content", sErr)
+		}
+	}
+	return nil
+}
+
+// httpProbe holds the outcome of a single successful HTTP request.
+type httpProbe struct {
+	resolve    int64
+	connection int64
+	tls        int64
+	processing int64
+	ttfb       int64
+	total      int64
+	reused     bool // connection kept alive from an earlier check
+
+	resp *http.Response // body already read and closed
+	body []byte
+}
+
+// Modes of aggregating the timings of several probes.
+const (
+	aggMin    = "min"
+	aggMedian = "median"
+	aggMean   = "mean"
+)
+
+// aggregate answers the aggregate of the given samples, as per the given
+// mode.
+func aggregate(vs []int64, mode string) int64 {
+	switch mode {
+	case aggMin:
+		return slices.Min(vs)
+	case aggMean:
+		var sum int64
+		for _, v := range vs {
+			sum += v
+		}
+		return sum / int64(len(vs))
+	default:
+		return percentile(vs, 50)
+	}
+}
+
+// probeHTTP makes a single request to the given site, and answers its
+// timings, provided that the response is acceptable.  It answers neither
+// a probe nor an error when the site signals planned maintenance.
+func (m *Monitor) probeHTTP(ctx context.Context, site *Site) (*httpProbe, error) {
 	writeError := func(err error) {
 		zLog.Error(site.Protocol,
 			zap.String("uri", site.Server),
@@ -101,52 +332,146 @@ func (m *Monitor) checkHTTPx(site *Site) error {
 		tTLSStart,
 		tTLSDone,
 		tFirstByte time.Time
+	var reused bool
 
 	// Configure the request tracer.
 	trace := &httptrace.ClientTrace{
 		DNSStart: func(info httptrace.DNSStartInfo) {
-			tDNSStart = time.Now()
+			tDNSStart = m.now()
 		},
 		DNSDone: func(info httptrace.DNSDoneInfo) {
-			tDNSDone = time.Now()
+			tDNSDone = m.now()
 		},
 		ConnectStart: func(network, addr string) {
-			tConnectStart = time.Now()
+			tConnectStart = m.now()
 		},
 		ConnectDone: func(network, addr string, err error) {
-			tConnectDone = time.Now()
+			tConnectDone = m.now()
 		},
 		TLSHandshakeStart: func() {
-			tTLSStart = time.Now()
+			tTLSStart = m.now()
 		},
 		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
-			tTLSDone = time.Now()
+			tTLSDone = m.now()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			reused = info.Reused
 		},
 		GotFirstResponseByte: func() {
-			tFirstByte = time.Now()
+			tFirstByte = m.now()
 		},
 	}
 
-	// Configure the request.
-	req, err := http.NewRequest(site.HTTPConfig.Method, fullURL, bytes.NewReader(site.HTTPConfig.Body))
+	// Configure the request.  A body file is read afresh on each check,
+	// so that its content may change between checks.
+	reqBody := []byte(site.HTTPConfig.Body)
+	if site.HTTPConfig.BodyFile != "" {
+		buf, err := os.ReadFile(site.HTTPConfig.BodyFile)
+		if err != nil {
+			writeError(err)
+			return nil, fmt.Errorf("reading body file: %w", err)
+		}
+		reqBody = buf
+	}
+	method := site.HTTPConfig.Method
+	if site.HTTPConfig.RemoteWriteProbe {
+		reqBody = remoteWritePayload(m.hostname, m.now())
+		if method == "" {
+			method = http.MethodPost
+		}
+	}
+	// Bound the whole exchange, including the reading of the body, so that
+	// an unresponsive server cannot hold the check up indefinitely.
+	total := site.httpTotalTimeoutMillis()
+	ctx, cFunc := context.WithTimeout(ctx, time.Duration(total)*time.Millisecond)
+	defer cFunc()
+	req, err := http.NewRequestWithContext(ctx, method, fullURL, bytes.NewReader(reqBody))
 	if err != nil {
 		writeError(err)
-		return err
+		return nil, err
+	}
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	if site.HTTPConfig.RemoteWriteProbe {
+		req.Header.Set("Content-Type", "application/x-protobuf")
+		req.Header.Set("Content-Encoding", "snappy")
+		req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	}
+	if site.HTTPConfig.BearerTokenFile != "" {
+		// Read afresh on each check, to pick up rotated tokens.
+		buf, err := os.ReadFile(site.HTTPConfig.BearerTokenFile)
+		if err != nil {
+			writeError(err)
+			return nil, fmt.Errorf("reading bearer token file: %w", err)
+		}
+		token := strings.TrimSpace(string(buf))
+		if token == "" {
+			err = fmt.Errorf("bearer token file is empty: %s", site.HTTPConfig.BearerTokenFile)
+			writeError(err)
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
 	}
 	_tr := httptrace.WithClientTrace(req.Context(), trace)
 	req = req.WithContext(_tr)
+	minTLS, _ := parseTLSVersion(site.HTTPConfig.MinTLSVersion)
+	dialTimeout := site.HTTPConfig.DialTimeoutMillis
+	if dialTimeout <= 0 {
+		dialTimeout = total
+	}
+	rootCAs, err := m.caPool(site)
+	if err != nil {
+		writeError(err)
+		return nil, fmt.Errorf("loading CA certificates: %w", err)
+	}
+	dialContext, err := m.httpDialContext(site, time.Duration(dialTimeout)*time.Millisecond)
+	if err != nil {
+		writeError(err)
+		return nil, fmt.Errorf("configuring SOCKS5 proxy: %w", err)
+	}
 	_trp := &http.Transport{
-		TLSClientConfig:   &tls.Config{InsecureSkipVerify: !site.HTTPConfig.VerifyCert},
-		DisableKeepAlives: true,
+		DialContext: dialContext,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: !m.verifyCert(site.HTTPConfig.VerifyCert),
+			MinVersion:         minTLS,
+			RootCAs:            rootCAs,
+		},
+		DisableKeepAlives: !site.HTTPConfig.KeepAlive,
+	}
+	if site.HTTPConfig.KeepAlive {
+		// Connections persist across checks, in the site's own transport.
+		v, _ := m.transports.LoadOrStore(site.key(), _trp)
+		_trp = v.(*http.Transport)
 	}
 
 	// Make the request.
-	start := time.Now()
+	start := m.now()
 	resp, err := _trp.RoundTrip(req)
 	if err != nil {
-		return fmt.Errorf("making request: %v", err)
+		class := classifyHTTPError(err)
+		zLog.Error(site.Protocol,
+			zap.String("uri", site.Server),
+			zap.String("url", site.HTTPConfig.URL),
+			zap.String("class", class),
+			zap.String("error", err.Error()))
+		if minTLS != 0 && class == httpErrTLS {
+			return nil, fmt.Errorf("making request: class: %s, minimum TLS version %s not met: %v", class, site.HTTPConfig.MinTLSVersion, err)
+		}
+		return nil, fmt.Errorf("making request: class: %s, err: %v", class, err)
 	}
 	defer resp.Body.Close()
+	if resp.TLS != nil {
+		zLog.Info("tls",
+			zap.String("uri", site.Server),
+			zap.String("version", tls.VersionName(resp.TLS.Version)))
+	}
+	// The certificate is checked whatever the status of the response.
+	if sErr := checkCertPin(site, resp); sErr != nil {
+		if n := notesOf(ctx); n != nil {
+			n.noteCert(sErr)
+		} else {
+			m.alert(site, "certificate", sErr)
+		}
+	}
 
 	// Write metrics.
 	tResolve := tDNSDone.Sub(tDNSStart).Milliseconds()
@@ -154,19 +479,12 @@ func (m *Monitor) checkHTTPx(site *Site) error {
 	tTLS := tTLSDone.Sub(tTLSStart).Milliseconds()
 	ttfb := tFirstByte.Sub(start).Milliseconds()
 	tProcessing := ttfb - tTLS - tConnection - tResolve
-	tServer := tConnection + tTLS + tProcessing
-	tTotal := time.Since(start).Milliseconds()
-	writeInfo := func() {
-		zLog.Info(site.Protocol,
-			zap.String("uri", site.Server),
-			zap.Int64("resolve", tResolve),
-			zap.Int64("connect", tConnection),
-			zap.Int64("tls", tTLS),
-			zap.Int64("processing", tProcessing),
-			zap.Int64("serverTotal", tServer),
-			zap.Int64("ttfb", ttfb),
-			zap.Int64("total", tTotal))
-	}
+	tTotal := m.since(start).Milliseconds()
+	tracePhase(ctx, "dns", tDNSStart, tDNSDone)
+	tracePhase(ctx, "connect", tConnectStart, tConnectDone)
+	tracePhase(ctx, "tls", tTLSStart, tTLSDone)
+	tracePhase(ctx, "processing", latest(start, latest(tConnectDone, tTLSDone)), tFirstByte)
+	traceHTTPStatus(ctx, resp.StatusCode)
 	writeError2 := func() {
 		zLog.Error(site.Protocol,
 			zap.String("uri", site.Server),
@@ -174,48 +492,482 @@ func (m *Monitor) checkHTTPx(site *Site) error {
 			zap.String("error", resp.Status))
 	}
 
+	// Read the (capped) body, decoding it if compressed.  The entire
+	// body is hashed, should a checksum be expected.
+	var sum hash.Hash
+	if site.HTTPConfig.ExpectedSHA256 != "" {
+		sum = sha256.New()
+	}
+	body, nEncoded, err := readBody(resp, sum)
+	// The response to a `HEAD` declares the length of a body not sent.
+	if declared := resp.ContentLength; req.Method != http.MethodHead && declared >= 0 && (declared <= MaxBodyBytes || sum != nil) {
+		zLog.Info("body",
+			zap.String("uri", site.Server),
+			zap.Int64("declared", declared),
+			zap.Int64("received", nEncoded))
+		// A connection closed early surfaces as an unexpected EOF.
+		if nEncoded != declared && (err == nil || errors.Is(err, io.ErrUnexpectedEOF)) {
+			err = fmt.Errorf("content length mismatch: declared %d, received %d bytes", declared, nEncoded)
+			writeError(err)
+			return nil, err
+		}
+	}
+	if err != nil {
+		writeError(err)
+		return nil, fmt.Errorf("reading body: %w", err)
+	}
+	if enc := resp.Header.Get("Content-Encoding"); enc != "" {
+		zLog.Info("body",
+			zap.String("uri", site.Server),
+			zap.String("encoding", enc),
+			zap.Int64("encoded", nEncoded),
+			zap.Int("decoded", len(body)))
+	}
+
+	if site.HTTPConfig.DebugDump {
+		dumpExchange(site, req, resp, body)
+	}
+
+	action, ok := site.HTTPConfig.statusPolicy(resp.StatusCode)
 	switch {
+	case ok:
+		// Per the configured policy, below.
+
 	case resp.StatusCode == 200:
-		// Intentionally left blank.
+		action = statusOK
 
-	case resp.StatusCode == 403:
-		if !site.HTTPConfig.Accept403 {
-			writeError2()
-			return fmt.Errorf("HTTP error : status : %d : %s", resp.StatusCode, resp.Status)
-		}
+	case site.HTTPConfig.RemoteWriteProbe && resp.StatusCode/100 == 2:
+		action = statusOK // typically, 204
+
+	case resp.StatusCode == 403 && site.HTTPConfig.Accept403:
+		action = statusOK
 
 	default:
-		writeError2()
-		return fmt.Errorf("HTTP error : status : %d : %s", resp.StatusCode, resp.Status)
+		action = statusFail
 	}
+	switch action {
+	case statusWarn:
+		zLog.Warn(site.Protocol,
+			zap.String("uri", site.Server),
+			zap.Int("status", resp.StatusCode),
+			zap.String("error", resp.Status))
+
+	case statusRetry:
+		writeError2()
+		return nil, &retryStatusError{
+			status: resp.Status,
+			delay:  retryAfter(resp.Header.Get("Retry-After"), m.now()),
+		}
 
-	writeInfo()
-	if tResolve >= int64(m.conf.ResolverTimeoutMillis) {
-		sErr := fmt.Errorf("DNS resolution time limit (%d) exceeded: %d ms", m.conf.ResolverTimeoutMillis, tResolve)
-		dErr := m.sendGmailAlert(site.Recipients, "dns", site.Server, sErr)
-		if dErr != nil {
-			zLog.Error("alert",
+	case statusMaintenance:
+		if ra := resp.Header.Get("Retry-After"); ra != "" {
+			zLog.Warn(site.Protocol,
 				zap.String("uri", site.Server),
-				zap.String("error", dErr.Error()))
+				zap.Int("status", resp.StatusCode),
+				zap.String("retryAfter", ra),
+				zap.String("maintenance", "planned maintenance signalled"))
+			return nil, nil
+		}
+		writeError2()
+		return nil, fmt.Errorf("HTTP error : status : %d : %s : no Retry-After", resp.StatusCode, resp.Status)
+
+	case statusFail:
+		writeError2()
+		return nil, fmt.Errorf("HTTP error : status : %d : %s", resp.StatusCode, resp.Status)
+	}
+	if err = checkJSONField(site, body); err != nil {
+		writeError(err)
+		return nil, err
+	}
+	if err = m.checkSchema(site, body); err != nil {
+		writeError(err)
+		return nil, err
+	}
+	if sum != nil {
+		if got := hex.EncodeToString(sum.Sum(nil)); !strings.EqualFold(got, site.HTTPConfig.ExpectedSHA256) {
+			err = fmt.Errorf("checksum mismatch: expected SHA-256 %s, got %s", site.HTTPConfig.ExpectedSHA256, got)
+			writeError(err)
+			return nil, err
 		}
 	}
-	if (tConnection + tTLS) >= int64(site.ConnectionTimeoutMillis) {
-		sErr := fmt.Errorf("connection + TLS time limit (%d) exceeded: %d ms", site.ConnectionTimeoutMillis, tConnection+tTLS)
-		dErr := m.sendGmailAlert(site.Recipients, "connection + TLS", site.Server, sErr)
-		if dErr != nil {
-			zLog.Error("alert",
+
+	return &httpProbe{
+		resolve:    tResolve,
+		connection: tConnection,
+		tls:        tTLS,
+		processing: tProcessing,
+		ttfb:       ttfb,
+		total:      tTotal,
+		reused:     reused,
+		resp:       resp,
+		body:       body,
+	}, nil
+}
+
+// Actions that may be configured for HTTP status codes.
+const (
+	statusOK          = "ok"          // success
+	statusWarn        = "warn"        // success, but logged as a warning
+	statusRetry       = "retry"       // retried, honouring `Retry-After`
+	statusMaintenance = "maintenance" // success, if `Retry-After` is present
+	statusFail        = "fail"
+)
+
+// DefStatusRetries is the number of retries for a status whose policy is
+// `retry`, in case of no specification in config.
+const DefStatusRetries = 1
+
+// maxRetryDelay caps the delay before retrying, regardless of the
+// server's `Retry-After`, so that a sweep is not held up for long.
+const maxRetryDelay = 5 * time.Second
+
+// statusPolicy answers the action configured for the given status code,
+// if any.  An exact code takes precedence over its class, as in `5xx`.
+func (hc *HTTPConfig) statusPolicy(code int) (string, bool) {
+	if a, ok := hc.StatusPolicies[strconv.Itoa(code)]; ok {
+		return a, true
+	}
+	a, ok := hc.StatusPolicies[strconv.Itoa(code/100)+"xx"]
+	return a, ok
+}
+
+// retryStatusError is answered when the server responds with a status
+// whose policy is `retry`.
+type retryStatusError struct {
+	status string
+	delay  time.Duration
+}
+
+func (e *retryStatusError) Error() string {
+	return fmt.Sprintf("HTTP error : status : %s : retries exhausted", e.status)
+}
+
+// retryAfter answers the delay requested by the given `Retry-After`
+// header value, which may be either in seconds or an HTTP date.  A
+// missing or invalid value yields a delay of one second.
+func retryAfter(v string, now time.Time) time.Duration {
+	d := time.Second
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		d = time.Duration(secs) * time.Second
+	} else if t, err := http.ParseTime(v); err == nil {
+		d = t.Sub(now)
+	}
+	return min(max(d, 0), maxRetryDelay)
+}
+
+// httpTotalTimeoutMillis answers the time limit of a whole HTTP exchange
+// with the site.
+func (s *Site) httpTotalTimeoutMillis() int64 {
+	if s.HTTPConfig.TotalTimeoutMillis > 0 {
+		return s.HTTPConfig.TotalTimeoutMillis
+	}
+	return DefHTTPTotalTimeoutFactor * s.TimeoutMillis
+}
+
+// checkHTTPRetrying checks the given site, retrying when the status
+// received calls for it.
+func (m *Monitor) checkHTTPRetrying(ctx context.Context, site *Site) error {
+	retries := site.HTTPConfig.StatusRetries
+	if retries == 0 {
+		retries = DefStatusRetries
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := m.checkHTTPx(ctx, site)
+		var rErr *retryStatusError
+		if !errors.As(err, &rErr) || attempt >= retries {
+			return err
+		}
+
+		zLog.Info(site.Protocol,
+			zap.String("uri", site.Server),
+			zap.String("status", rErr.status),
+			zap.Int("attempt", attempt+1),
+			zap.Duration("delay", rErr.delay))
+		select {
+		case <-m.clock.After(rErr.delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// checkContentHash computes the SHA-256 hash of the (capped) response
+// body, and compares it with that observed in the previous check of the
+// same site.
+func (m *Monitor) checkContentHash(site *Site, body []byte) error {
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	zLog.Info("content",
+		zap.String("uri", site.Server),
+		zap.String("sha256", hash))
+
+	var prev string
+	m.withState(site, func(st *siteState) {
+		prev = st.contentHash
+		st.contentHash = hash
+	})
+	if prev != "" && prev != hash {
+		return fmt.Errorf("content changed: hash %s, previously %s", hash, prev)
+	}
+
+	return nil
+}
+
+// checkJSONField parses the (capped) response body as JSON, and compares
+// the value at the expected field path, if any is specified.  The path
+// separates object keys and array indices with dots, as in `checks.0.db`.
+// String values are compared as such; others, in their JSON encoding.
+func checkJSONField(site *Site, body []byte) error {
+	hc := &site.HTTPConfig
+	if hc.ExpectJSONField == "" {
+		return nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return fmt.Errorf("response body is not valid JSON: %w", err)
+	}
+	for _, k := range strings.Split(hc.ExpectJSONField, ".") {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			e, ok := t[k]
+			if !ok {
+				return fmt.Errorf("JSON field '%s' absent in response", hc.ExpectJSONField)
+			}
+			v = e
+
+		case []interface{}:
+			i, err := strconv.Atoi(k)
+			if err != nil || i < 0 || i >= len(t) {
+				return fmt.Errorf("JSON field '%s' absent in response", hc.ExpectJSONField)
+			}
+			v = t[i]
+
+		default:
+			return fmt.Errorf("JSON field '%s' absent in response", hc.ExpectJSONField)
+		}
+	}
+
+	got, ok := v.(string)
+	if !ok {
+		buf, _ := json.Marshal(v)
+		got = string(buf)
+	}
+	if got != hc.ExpectJSONValue {
+		return fmt.Errorf("JSON field '%s' mismatch: got '%s', expected '%s'", hc.ExpectJSONField, got, hc.ExpectJSONValue)
+	}
+	return nil
+}
+
+// checkCertPin compares the leaf certificate presented by the server
+// against the expected fingerprint and issuer, if any are specified.
+func checkCertPin(site *Site, resp *http.Response) error {
+	hc := &site.HTTPConfig
+	if hc.ExpectedCertFingerprint == "" && hc.ExpectedIssuer == "" {
+		return nil
+	}
+	if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+		return fmt.Errorf("certificate pin specified, but no certificate presented")
+	}
+
+	leaf := resp.TLS.PeerCertificates[0]
+	sum := sha256.Sum256(leaf.Raw)
+	fp := hex.EncodeToString(sum[:])
+	issuer := leaf.Issuer.String()
+
+	if hc.ExpectedCertFingerprint != "" {
+		want := strings.ToLower(strings.ReplaceAll(hc.ExpectedCertFingerprint, ":", ""))
+		if fp != want {
+			zLog.Error("certificate",
 				zap.String("uri", site.Server),
-				zap.String("error", dErr.Error()))
+				zap.String("fingerprint", fp),
+				zap.String("expected", want),
+				zap.String("issuer", issuer))
+			return fmt.Errorf("certificate fingerprint mismatch: got %s, expected %s", fp, want)
 		}
 	}
-	if tProcessing >= site.TimeoutMillis {
-		sErr := fmt.Errorf("processing time limit (%d) exceeded: %d ms", site.TimeoutMillis, tProcessing)
-		dErr := m.sendGmailAlert(site.Recipients, site.Protocol, site.Server, sErr)
-		if dErr != nil {
-			zLog.Error("alert",
+	if hc.ExpectedIssuer != "" {
+		if hc.ExpectedIssuer != issuer && hc.ExpectedIssuer != leaf.Issuer.CommonName {
+			zLog.Error("certificate",
 				zap.String("uri", site.Server),
-				zap.String("error", dErr.Error()))
+				zap.String("fingerprint", fp),
+				zap.String("issuer", issuer),
+				zap.String("expected", hc.ExpectedIssuer))
+			return fmt.Errorf("certificate issuer mismatch: got '%s', expected '%s'", issuer, hc.ExpectedIssuer)
 		}
 	}
+
 	return nil
 }
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// readBody reads up to `MaxBodyBytes` of the response body, transparently
+// decoding `gzip` and `deflate` content encodings.  It answers the
+// decoded body, and the number of bytes read off the wire.  Should a
+// hash be given, the entire decoded body is streamed through it, though
+// only the capped body is answered.
+func readBody(resp *http.Response, h hash.Hash) ([]byte, int64, error) {
+	var wire io.Reader = resp.Body
+	if h == nil {
+		wire = io.LimitReader(resp.Body, MaxBodyBytes)
+	}
+	cr := &countingReader{r: wire}
+
+	var r io.Reader = cr
+	switch resp.Header.Get("Content-Encoding") {
+	case "gzip":
+		gr, err := gzip.NewReader(cr)
+		if err != nil {
+			return nil, cr.n, err
+		}
+		defer gr.Close()
+		r = gr
+
+	case "deflate":
+		zr, err := zlib.NewReader(cr)
+		if err != nil {
+			return nil, cr.n, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r, MaxBodyBytes))
+	if err != nil {
+		return nil, cr.n, err
+	}
+	if h != nil {
+		h.Write(body)
+		if _, err = io.Copy(h, r); err != nil {
+			return nil, cr.n, err
+		}
+	} else if r != io.Reader(cr) {
+		// A decoded body may reach the cap well before the wire does;
+		// the rest of the (capped) wire is drained, so that its length
+		// is counted in full.
+		if _, err = io.Copy(io.Discard, cr); err != nil {
+			return nil, cr.n, err
+		}
+	}
+	return body, cr.n, nil
+}
+
+// httpDialContext answers the function with which connections to the
+// given site are made: through its SOCKS5 proxy, if one is specified, or
+// directly otherwise.  The proxy resolves the server's name.
+func (m *Monitor) httpDialContext(site *Site, timeout time.Duration) (func(context.Context, string, string) (net.Conn, error), error) {
+	d := m.dialer(site, timeout)
+	hc := &site.HTTPConfig
+	if hc.SOCKS5Proxy == "" {
+		return d.DialContext, nil
+	}
+
+	var auth *proxy.Auth
+	if hc.SOCKS5Username != "" {
+		auth = &proxy.Auth{User: hc.SOCKS5Username, Password: hc.SOCKS5Password}
+	}
+	pd, err := proxy.SOCKS5("tcp", hc.SOCKS5Proxy, auth, d)
+	if err != nil {
+		return nil, err
+	}
+	return pd.(proxy.ContextDialer).DialContext, nil
+}
+
+// parseTLSVersion answers the TLS version constant for the given version
+// string.  An empty string answers zero, i.e. the library default.
+func parseTLSVersion(v string) (uint16, error) {
+	switch v {
+	case "":
+		return 0, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unknown TLS version: %s", v)
+	}
+}
+
+// Classes of HTTP request errors.  Each points to a different root cause.
+const (
+	httpErrTimeout = "timeout"
+	httpErrReset   = "reset"
+	httpErrRefused = "refused"
+	httpErrDNS     = "dns"
+	httpErrTLS     = "tls"
+	httpErrOther   = "other"
+)
+
+// classifyHTTPError answers the class of the given error returned by a
+// round trip.
+func classifyHTTPError(err error) string {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &dnsErr):
+		return httpErrDNS
+	case isTLSError(err):
+		return httpErrTLS
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return httpErrReset
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return httpErrRefused
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return httpErrTimeout
+	}
+	return httpErrOther
+}
+
+// isTLSError answers whether the given error arose in the TLS handshake.
+func isTLSError(err error) bool {
+	var alert tls.AlertError
+	var recErr tls.RecordHeaderError
+	return errors.As(err, &alert) || errors.As(err, &recErr) || strings.Contains(err.Error(), "tls:")
+}
+
+// dumpBytes is the number of leading body bytes included in a dump.
+const dumpBytes = 512
+
+// dumpExchange logs the request sent, and the response received, for
+// debugging.  Credentials in headers are redacted.
+func dumpExchange(site *Site, req *http.Request, resp *http.Response, body []byte) {
+	if len(body) > dumpBytes {
+		body = body[:dumpBytes]
+	}
+	zLog.Info("dump",
+		zap.String("uri", site.Server),
+		zap.String("method", req.Method),
+		zap.String("url", req.URL.String()),
+		zap.Any("requestHeaders", redactHeaders(req.Header)),
+		zap.Int("status", resp.StatusCode),
+		zap.Any("responseHeaders", redactHeaders(resp.Header)),
+		zap.ByteString("body", body))
+}
+
+// redactHeaders answers a copy of the given headers, with the values of
+// those carrying credentials redacted.
+func redactHeaders(h http.Header) http.Header {
+	res := h.Clone()
+	for _, k := range []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"} {
+		if _, ok := res[k]; ok {
+			res[k] = []string{"REDACTED"}
+		}
+	}
+	return res
+}
@@ -0,0 +1,481 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// httpSite answers a site that checks the given test server.
+func httpSite(t *testing.T, srv *httptest.Server) *Site {
+	t.Helper()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ := net.SplitHostPort(u.Host)
+	p, _ := strconv.Atoi(port)
+	return &Site{
+		Protocol:      u.Scheme,
+		Server:        host,
+		HTTPConfig:    HTTPConfig{Port: p},
+		TimeoutMillis: 2000,
+
+		ConnectionTimeoutMillis: 2000,
+	}
+}
+
+// lyingServer answers a server that declares a body longer than it
+// sends, and then closes the connection.
+func lyingServer(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 100\r\nConnection: close\r\n\r\n")
+		if r.Method != http.MethodHead {
+			buf.WriteString("truncated")
+		}
+		buf.Flush()
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestContentLengthMismatch(t *testing.T) {
+	m, _ := newTestMonitor(&Config{})
+	srv := lyingServer(t)
+
+	site := httpSite(t, srv)
+	_, err := m.probeHTTP(context.Background(), site)
+	if err == nil || !strings.Contains(err.Error(), "content length mismatch: declared 100, received 9") {
+		t.Fatalf("expected a content length mismatch; got %v", err)
+	}
+
+	site.HTTPConfig.Method = http.MethodHead
+	if _, err = m.probeHTTP(context.Background(), site); err != nil {
+		t.Fatalf("HEAD: %v", err)
+	}
+}
+
+func TestUnresponsiveServer(t *testing.T) {
+	m, _ := newTestMonitor(&Config{})
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(srv.Close)
+	defer close(release)
+
+	site := httpSite(t, srv)
+	site.TimeoutMillis = 100
+	tb := time.Now()
+	_, err := m.probeHTTP(context.Background(), site)
+	if err == nil || !strings.Contains(err.Error(), "class: "+httpErrTimeout) {
+		t.Fatalf("expected a timeout; got %v", err)
+	}
+	if d := time.Since(tb); d > time.Second {
+		t.Fatalf("check held for %v", d)
+	}
+}
+
+func TestAlertPerFailingURL(t *testing.T) {
+	m, _ := newTestMonitor(&Config{HeartbeatSeconds: 60, ResolverTimeoutMillis: DefResolverTimeoutMillis})
+	rn := &recordingNotifier{name: "rec"}
+	m.notifiers = []Notifier{rn}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ready":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/metrics":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	site := httpSite(t, srv)
+	site.HTTPConfig.URLs = []string{"health", "ready", "metrics"}
+	if err := m.checkSite(*site); err == nil {
+		t.Fatal("expected failure")
+	}
+	if n := rn.count(); n != 2 {
+		t.Fatalf("%d alerts, expected 2", n)
+	}
+	for i, path := range []string{"/ready", "/metrics"} {
+		if e := rn.alerts[i].Err.Error(); !strings.HasPrefix(e, path+" : ") {
+			t.Errorf("alert #%d: %s, expected one about %s", i, e, path)
+		}
+	}
+}
+
+func TestDefaultVerifyCert(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(srv.Close)
+	trusted := x509.NewCertPool()
+	trusted.AddCert(srv.Certificate())
+	no := false
+
+	tests := []struct {
+		name          string
+		defaultVerify bool
+		verify        *bool
+		roots         *x509.CertPool
+		ok            bool
+	}{
+		{"insecure default", false, nil, nil, true},
+		{"secure default, untrusted", true, nil, nil, false},
+		{"secure default, opted out", true, &no, nil, true},
+		{"secure default, trusted", true, nil, trusted, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := newTestMonitor(&Config{DefaultVerifyCert: tt.defaultVerify})
+			m.rootCAs = tt.roots
+			site := httpSite(t, srv)
+			site.HTTPConfig.VerifyCert = tt.verify
+
+			_, err := m.probeHTTP(context.Background(), site)
+			if ok := err == nil; ok != tt.ok {
+				t.Fatalf("ok %v, expected %v; err: %v", ok, tt.ok, err)
+			}
+			if err != nil && !strings.Contains(err.Error(), "class: "+httpErrTLS) {
+				t.Fatalf("expected a TLS failure; got %v", err)
+			}
+		})
+	}
+}
+
+func TestCompressedResponses(t *testing.T) {
+	const payload = `{"status": "ok"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enc := strings.TrimPrefix(r.URL.Path, "/")
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), enc) {
+			t.Errorf("encoding %s not accepted: %s", enc, r.Header.Get("Accept-Encoding"))
+		}
+		var buf bytes.Buffer
+		var zw io.WriteCloser
+		switch enc {
+		case "gzip":
+			zw = gzip.NewWriter(&buf)
+		case "deflate":
+			zw = zlib.NewWriter(&buf)
+		}
+		zw.Write([]byte(payload))
+		zw.Close()
+		w.Header().Set("Content-Encoding", enc)
+		w.Write(buf.Bytes())
+	}))
+	t.Cleanup(srv.Close)
+	m, _ := newTestMonitor(&Config{})
+
+	for _, enc := range []string{"gzip", "deflate"} {
+		t.Run(enc, func(t *testing.T) {
+			site := httpSite(t, srv)
+			site.HTTPConfig.URL = enc
+			site.HTTPConfig.ExpectJSONField = "status"
+			site.HTTPConfig.ExpectJSONValue = "ok"
+
+			p, err := m.probeHTTP(context.Background(), site)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(p.body) != payload {
+				t.Fatalf("body %q, expected %q", p.body, payload)
+			}
+		})
+	}
+}
+
+func TestLargeCompressedResponse(t *testing.T) {
+	// Far more than `MaxBodyBytes` decoded, far less on the wire.
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(bytes.Repeat([]byte("a"), 4*MaxBodyBytes))
+	zw.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+		w.Write(buf.Bytes())
+	}))
+	t.Cleanup(srv.Close)
+	m, _ := newTestMonitor(&Config{})
+
+	p, err := m.probeHTTP(context.Background(), httpSite(t, srv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.body) != MaxBodyBytes {
+		t.Fatalf("body of %d bytes, expected %d", len(p.body), MaxBodyBytes)
+	}
+}
+
+func TestHTTPErrorClasses(t *testing.T) {
+	hangUp := func(abort bool) *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if abort {
+				// Discarding unsent data resets the connection.
+				conn.(*net.TCPConn).SetLinger(0)
+			}
+			conn.Close()
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(plain.Close)
+	host, closed := unreachableAddr(t)
+
+	tests := []struct {
+		name  string
+		site  func() *Site
+		class string
+	}{
+		{"reset", func() *Site { return httpSite(t, hangUp(true)) }, httpErrReset},
+		{"closed", func() *Site { return httpSite(t, hangUp(false)) }, httpErrReset},
+		{"refused", func() *Site {
+			return &Site{Protocol: "http", Server: host, HTTPConfig: HTTPConfig{Port: closed}, TimeoutMillis: 2000}
+		}, httpErrRefused},
+		{"dns", func() *Site {
+			return &Site{Protocol: "http", Server: "absent.invalid", HTTPConfig: HTTPConfig{Port: 80}, TimeoutMillis: 2000}
+		}, httpErrDNS},
+		{"tls", func() *Site {
+			site := httpSite(t, plain)
+			site.Protocol = "https"
+			return site
+		}, httpErrTLS},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := newTestMonitor(&Config{})
+			_, err := m.probeHTTP(context.Background(), tt.site())
+			if err == nil || !strings.Contains(err.Error(), "class: "+tt.class+",") {
+				t.Fatalf("expected class %s; got %v", tt.class, err)
+			}
+		})
+	}
+}
+
+// socks5Request is a CONNECT request received by a stub SOCKS5 proxy.
+type socks5Request struct {
+	user, password, target string
+}
+
+// socks5Proxy answers the address of a stub SOCKS5 proxy that requires
+// username/password authentication, and relays every connection to the
+// given address, whatever its requested target.  The requests received
+// are sent on the answered channel.
+func socks5Proxy(t *testing.T, relayTo string) (string, <-chan socks5Request) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	reqs := make(chan socks5Request, 10)
+	serve := func(conn net.Conn) error {
+		defer conn.Close()
+		var req socks5Request
+		read := func(n int) ([]byte, error) {
+			buf := make([]byte, n)
+			_, err := io.ReadFull(conn, buf)
+			return buf, err
+		}
+
+		// Greeting: only username/password authentication is accepted.
+		hdr, err := read(2)
+		if err != nil {
+			return err
+		}
+		if _, err = read(int(hdr[1])); err != nil {
+			return err
+		}
+		conn.Write([]byte{5, 2})
+
+		// Authentication, as per RFC 1929.
+		hdr, err = read(2)
+		if err != nil {
+			return err
+		}
+		user, err := read(int(hdr[1]))
+		if err != nil {
+			return err
+		}
+		plen, err := read(1)
+		if err != nil {
+			return err
+		}
+		password, err := read(int(plen[0]))
+		if err != nil {
+			return err
+		}
+		req.user, req.password = string(user), string(password)
+		conn.Write([]byte{1, 0})
+
+		// CONNECT request.
+		hdr, err = read(4)
+		if err != nil {
+			return err
+		}
+		var host string
+		switch hdr[3] {
+		case 1:
+			ip, err := read(4)
+			if err != nil {
+				return err
+			}
+			host = net.IP(ip).String()
+		case 3:
+			n, err := read(1)
+			if err != nil {
+				return err
+			}
+			name, err := read(int(n[0]))
+			if err != nil {
+				return err
+			}
+			host = string(name)
+		default:
+			return fmt.Errorf("address type %d", hdr[3])
+		}
+		port, err := read(2)
+		if err != nil {
+			return err
+		}
+		req.target = net.JoinHostPort(host, strconv.Itoa(int(port[0])<<8|int(port[1])))
+		reqs <- req
+
+		upstream, err := net.Dial("tcp", relayTo)
+		if err != nil {
+			return err
+		}
+		defer upstream.Close()
+		conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+		go io.Copy(upstream, conn)
+		_, err = io.Copy(conn, upstream)
+		return err
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+	return l.Addr().String(), reqs
+}
+
+func TestSOCKS5Proxy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(srv.Close)
+	proxyAddr, reqs := socks5Proxy(t, srv.Listener.Addr().String())
+	m, _ := newTestMonitor(&Config{})
+
+	site := httpSite(t, srv)
+	// The name is resolved by the proxy alone.
+	site.Server = "backend.invalid"
+	site.HTTPConfig.SOCKS5Proxy = proxyAddr
+	site.HTTPConfig.SOCKS5Username = "hb"
+	site.HTTPConfig.SOCKS5Password = "secret"
+	if _, err := m.probeHTTP(context.Background(), site); err != nil {
+		t.Fatal(err)
+	}
+
+	req := <-reqs
+	want := socks5Request{"hb", "secret", net.JoinHostPort(site.Server, strconv.Itoa(site.HTTPConfig.Port))}
+	if req != want {
+		t.Fatalf("request %+v, expected %+v", req, want)
+	}
+}
+
+func TestCertPinOnFailedStatus(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(srv.Close)
+	m, _ := newTestMonitor(&Config{HeartbeatSeconds: 60})
+	rn := &recordingNotifier{name: "rec"}
+	m.notifiers = []Notifier{rn}
+
+	site := httpSite(t, srv)
+	site.HTTPConfig.ProbesPerCheck = 3
+	site.HTTPConfig.ExpectedCertFingerprint = strings.Repeat("00", 32)
+	if err := m.checkSite(*site); err == nil {
+		t.Fatal("expected a failed check")
+	}
+
+	var certAlerts int
+	for _, a := range rn.alerts {
+		if a.Service == "certificate" {
+			certAlerts++
+		}
+	}
+	if certAlerts != 1 {
+		t.Fatalf("%d certificate alerts, expected 1", certAlerts)
+	}
+}
+
+func TestCombinedTraceDNSAlerts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name string
+		urls []string
+		want []string // services alerted
+	}{
+		{"service up", []string{"up"}, []string{"dns"}},
+		{"service down", []string{"up", "down"}, []string{"http"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// No resolution is fast enough for a limit of 0.
+			m, _ := newTestMonitor(&Config{HeartbeatSeconds: 60, CombineDNSAlerts: true})
+			rn := &recordingNotifier{name: "rec"}
+			m.notifiers = []Notifier{rn}
+			site := httpSite(t, srv)
+			site.HTTPConfig.URLs = tt.urls
+
+			m.checkSite(*site)
+			var got []string
+			for _, a := range rn.alerts {
+				got = append(got, a.Service)
+				if a.Service == "http" && !strings.HasPrefix(a.Err.Error(), "DNS : DNS resolution time limit") {
+					t.Fatalf("DNS status not combined: %v", a.Err)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("alerted %v, expected %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// checkKubernetes checks the `/healthz` and `/readyz` endpoints of a
+// Kubernetes API server, as per the given specification.  The verbose
+// output of the latter is parsed, so that the failing components are
+// reported by name.
+func (m *Monitor) checkKubernetes(ctx context.Context, site *Site) error {
+	kc := &site.KubernetesConfig
+	client, err := m.kubernetesClient(site)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrConfig, err.Error())
+	}
+	token, err := kubernetesToken(kc)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrConfig, err.Error())
+	}
+	u := &url.URL{Scheme: "https", Host: net.JoinHostPort(site.Server, strconv.Itoa(kc.Port))}
+
+	tb := time.Now()
+	u.Path = "/healthz"
+	if status, _, err := kubernetesGet(ctx, client, u.String(), token); err != nil || status != http.StatusOK {
+		if err == nil {
+			err = fmt.Errorf("status: %d", status)
+		}
+		zLog.Error(site.Protocol,
+			zap.String("uri", site.Server),
+			zap.String("endpoint", u.Path),
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: check health, err: %s", err.Error())
+	}
+	tHealth := time.Since(tb).Milliseconds()
+	tr := time.Now()
+	tracePhase(ctx, "healthz", tb, tr)
+
+	u.Path, u.RawQuery = "/readyz", "verbose"
+	status, buf, err := kubernetesGet(ctx, client, u.String(), token)
+	if err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("uri", site.Server),
+			zap.String("endpoint", u.Path),
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: check readiness, err: %s", err.Error())
+	}
+
+	tracePhase(ctx, "readyz", tr, time.Now())
+	failing := readyzFailures(buf)
+	zLog.Info(site.Protocol,
+		zap.String("uri", site.Server),
+		zap.Int("status", status),
+		zap.Strings("failing", failing),
+		zap.Int64("healthz", tHealth),
+		zap.Int64("total", time.Since(tb).Milliseconds()))
+
+	// Only the required components matter, if any are specified.
+	if len(kc.Components) > 0 {
+		failing = slices.DeleteFunc(failing, func(c string) bool {
+			return !slices.ContainsFunc(kc.Components, func(req string) bool {
+				return c == req || strings.HasSuffix(c, "/"+req)
+			})
+		})
+	} else if len(failing) == 0 && status != http.StatusOK {
+		return fmt.Errorf("API server not ready : status : %d", status)
+	}
+	if len(failing) > 0 {
+		return fmt.Errorf("API server not ready : failing components : %s", strings.Join(failing, ", "))
+	}
+	return nil
+}
+
+// kubernetesClient answers an HTTP client that authenticates to the API
+// server with the configured CA and client certificates, if any.
+func (m *Monitor) kubernetesClient(site *Site) (*http.Client, error) {
+	kc := &site.KubernetesConfig
+	tlsConf := &tls.Config{InsecureSkipVerify: !m.verifyCert(kc.VerifyCert)}
+	if kc.CACertFile != "" {
+		buf, err := os.ReadFile(kc.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA certificate: %w", err)
+		}
+		tlsConf.RootCAs = x509.NewCertPool()
+		if !tlsConf.RootCAs.AppendCertsFromPEM(buf) {
+			return nil, fmt.Errorf("no certificates in: %s", kc.CACertFile)
+		}
+	}
+	if kc.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(kc.ClientCertFile, kc.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsConf.Certificates = []tls.Certificate{cert}
+	}
+
+	timeout := time.Duration(site.TimeoutMillis) * time.Millisecond
+	tr := &http.Transport{
+		DialContext:       m.dialer(site, timeout).DialContext,
+		TLSClientConfig:   tlsConf,
+		DisableKeepAlives: true,
+	}
+	return &http.Client{Transport: tr, Timeout: timeout}, nil
+}
+
+// kubernetesToken answers the configured bearer token, reading it afresh
+// from its file, if so specified, so that rotated tokens are picked up.
+func kubernetesToken(kc *KubernetesConfig) (string, error) {
+	if kc.TokenFile == "" {
+		return kc.Token, nil
+	}
+	buf, err := os.ReadFile(kc.TokenFile)
+	if err != nil {
+		return "", fmt.Errorf("reading token file: %w", err)
+	}
+	return strings.TrimSpace(string(buf)), nil
+}
+
+// kubernetesGet makes a GET request to the API server, and answers the
+// status and the (capped) body of the response.
+func kubernetesGet(ctx context.Context, client *http.Client, u, token string) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	buf, err := io.ReadAll(io.LimitReader(resp.Body, MaxBodyBytes))
+	if err != nil {
+		return 0, nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return 0, nil, fmt.Errorf("status: %d : %s", resp.StatusCode, resp.Status)
+	}
+	return resp.StatusCode, buf, nil
+}
+
+// readyzFailures answers the names of the failing checks in the verbose
+// output of `/readyz`, whose lines are of the form `[+]name ok` or
+// `[-]name failed: reason`.
+func readyzFailures(buf []byte) []string {
+	var failing []string
+	sc := bufio.NewScanner(bytes.NewReader(buf))
+	for sc.Scan() {
+		line, ok := strings.CutPrefix(sc.Text(), "[-]")
+		if !ok {
+			continue
+		}
+		if name, _, _ := strings.Cut(line, " "); name != "" {
+			failing = append(failing, name)
+		}
+	}
+	return failing
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"go.uber.org/zap"
+)
+
+// checkLDAP binds to the given LDAP server, as per the given
+// specification.  Optionally, it searches the base DN.
+func (m *Monitor) checkLDAP(ctx context.Context, site *Site) error {
+	// Connection setup.
+	lc := &site.LDAPConfig
+	timeout := time.Duration(site.TimeoutMillis) * time.Millisecond
+	tlsConf := &tls.Config{
+		InsecureSkipVerify: !m.verifyCert(lc.VerifyCert),
+		ServerName:         site.Server,
+	}
+	scheme := "ldap"
+	if lc.TLS {
+		scheme = "ldaps"
+	}
+	addr := fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(site.Server, strconv.Itoa(lc.Port)))
+
+	tb := time.Now()
+	conn, err := ldap.DialURL(addr,
+		ldap.DialWithDialer(&net.Dialer{Timeout: timeout}),
+		ldap.DialWithTLSConfig(tlsConf))
+	if err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: connect to server, err: %s", err.Error())
+	}
+	defer conn.Close()
+	conn.SetTimeout(timeout)
+	// The client does not take a context; closing the connection
+	// unblocks a pending operation.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if lc.StartTLS && !lc.TLS {
+		if err = conn.StartTLS(tlsConf); err != nil {
+			zLog.Error(site.Protocol,
+				zap.String("error", err.Error()))
+			return fmt.Errorf("action: start TLS, err: %s", err.Error())
+		}
+	}
+
+	// Bind with the configured credentials.
+	if err = conn.Bind(lc.BindDN, lc.Password); err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("bindDn", lc.BindDN),
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: bind, err: %s", err.Error())
+	}
+	tBind := time.Since(tb).Milliseconds()
+	ts := time.Now()
+	tracePhase(ctx, "bind", tb, ts)
+
+	// Search the base DN, if asked for.
+	if lc.BaseDN != "" {
+		req := ldap.NewSearchRequest(lc.BaseDN,
+			ldap.ScopeBaseObject, ldap.NeverDerefAliases, 1, int(timeout.Seconds())+1, false,
+			"(objectClass=*)", []string{"dn"}, nil)
+		if _, err = conn.Search(req); err != nil {
+			zLog.Error(site.Protocol,
+				zap.String("baseDn", lc.BaseDN),
+				zap.String("error", err.Error()))
+			return fmt.Errorf("action: search, err: %s", err.Error())
+		}
+		tracePhase(ctx, "search", ts, time.Now())
+	}
+
+	zLog.Info(site.Protocol,
+		zap.String("server", site.Server),
+		zap.Int64("bind", tBind),
+		zap.Int64("total", time.Since(tb).Milliseconds()))
+	return nil
+}
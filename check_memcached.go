@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// checkMemcached issues a `version` command to the given memcached
+// server, as per the given specification.
+func (m *Monitor) checkMemcached(ctx context.Context, site *Site) error {
+	addr := net.JoinHostPort(site.Server, strconv.Itoa(site.MemcachedConfig.Port))
+	timeout := time.Duration(site.TimeoutMillis) * time.Millisecond
+
+	tb := time.Now()
+	conn, err := m.dialer(site, timeout).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: connect to memcached, err: %s", err.Error())
+	}
+	defer conn.Close()
+	conn.SetDeadline(tb.Add(timeout))
+	tc := time.Now()
+	tracePhase(ctx, "connect", tb, tc)
+
+	// Execute the command, and read its response.
+	if _, err = conn.Write([]byte("version\r\n")); err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: send command, err: %s", err.Error())
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: read response, err: %s", err.Error())
+	}
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "VERSION ") {
+		zLog.Error(site.Protocol,
+			zap.String("error", line))
+		return fmt.Errorf("action: read response, err: unexpected response: %s", line)
+	}
+	te := time.Now()
+	tracePhase(ctx, "query", tc, te)
+
+	zLog.Info(site.Protocol,
+		zap.String("server", site.Server),
+		zap.String("version", strings.TrimPrefix(line, "VERSION ")),
+		zap.Int64("total", te.Sub(tb).Milliseconds()))
+	return nil
+}
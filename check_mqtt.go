@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"go.uber.org/zap"
+)
+
+// checkMQTT connects to the given MQTT broker, as per the given
+// specification.  Optionally, it subscribes to a topic, and waits for a
+// retained message on it.
+func (m *Monitor) checkMQTT(ctx context.Context, site *Site) error {
+	// Connection setup.
+	scheme := "tcp"
+	if site.MQTTConfig.TLS {
+		scheme = "ssl"
+	}
+	timeout := time.Duration(site.TimeoutMillis) * time.Millisecond
+
+	opts := mqtt.NewClientOptions()
+	opts.AddBroker(fmt.Sprintf("%s://%s:%d", scheme, site.Server, site.MQTTConfig.Port))
+	opts.SetClientID(fmt.Sprintf("heartbeat-%d", time.Now().UnixNano()))
+	opts.SetUsername(site.MQTTConfig.Username)
+	opts.SetPassword(site.MQTTConfig.Password)
+	opts.SetConnectTimeout(timeout)
+	opts.SetAutoReconnect(false)
+	opts.SetCleanSession(true)
+	if site.MQTTConfig.TLS {
+		opts.SetTLSConfig(&tls.Config{InsecureSkipVerify: !m.verifyCert(site.MQTTConfig.VerifyCert)})
+	}
+	cl := mqtt.NewClient(opts)
+
+	// Connect to the broker.  A connection attempt that times out is
+	// abandoned, too.
+	tb := time.Now()
+	tok := cl.Connect()
+	defer cl.Disconnect(250)
+	if !tok.WaitTimeout(timeout) {
+		zLog.Error(site.Protocol,
+			zap.String("uri", site.Server),
+			zap.String("error", "connect timed out"))
+		return fmt.Errorf("action: connect to broker, err: timed out after %d ms", site.TimeoutMillis)
+	}
+	if err := tok.Error(); err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("uri", site.Server),
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: connect to broker, err: %s", err.Error())
+	}
+	tConnect := time.Since(tb).Milliseconds()
+	tracePhase(ctx, "connect", tb, time.Now())
+
+	// Wait for a retained message on the topic, if asked for.
+	if site.MQTTConfig.Topic != "" {
+		got := make(chan struct{}, 1)
+		tok = cl.Subscribe(site.MQTTConfig.Topic, 0, func(_ mqtt.Client, msg mqtt.Message) {
+			// Messages published live say nothing of the retained one.
+			if !msg.Retained() {
+				return
+			}
+			select {
+			case got <- struct{}{}:
+			default:
+			}
+		})
+		remaining := timeout - time.Since(tb)
+		if !tok.WaitTimeout(remaining) {
+			zLog.Error(site.Protocol,
+				zap.String("uri", site.Server),
+				zap.String("topic", site.MQTTConfig.Topic),
+				zap.String("error", "subscribe timed out"))
+			return fmt.Errorf("action: subscribe to topic, err: timed out after %d ms", site.TimeoutMillis)
+		}
+		if err := tok.Error(); err != nil {
+			zLog.Error(site.Protocol,
+				zap.String("uri", site.Server),
+				zap.String("topic", site.MQTTConfig.Topic),
+				zap.String("error", err.Error()))
+			return fmt.Errorf("action: subscribe to topic, err: %s", err.Error())
+		}
+
+		select {
+		case <-got:
+			// Intentionally left blank.
+
+		case <-time.After(timeout - time.Since(tb)):
+			zLog.Error(site.Protocol,
+				zap.String("uri", site.Server),
+				zap.String("topic", site.MQTTConfig.Topic),
+				zap.String("error", "no retained message"))
+			return fmt.Errorf("action: await retained message, err: none received within %d ms", site.TimeoutMillis)
+
+		case <-ctx.Done():
+			return fmt.Errorf("action: await retained message, err: %s", ctx.Err().Error())
+		}
+	}
+
+	zLog.Info(site.Protocol,
+		zap.String("uri", site.Server),
+		zap.Int64("connect", tConnect),
+		zap.Int64("total", time.Since(tb).Milliseconds()))
+	return nil
+}
@@ -12,12 +12,12 @@ import (
 
 // checkMySQL makes a connection request to the given server, as per the
 // given specification.
-func (m *Monitor) checkMySQL(site *Site) error {
+func (m *Monitor) checkMySQL(ctx context.Context, site *Site) error {
 	// Connection setup.
 	dbConf := mysql.NewConfig()
 	dbConf.User = site.MySQLConfig.Username
 	dbConf.Passwd = site.MySQLConfig.Password
-	dbConf.Net = "tcp"
+	dbConf.Net = m.mysqlNet(site)
 	dbConf.Addr = fmt.Sprintf("%s:%d", site.Server, site.MySQLConfig.Port)
 	dbConf.InterpolateParams = true
 	dbConf.ParseTime = true
@@ -35,21 +35,31 @@ func (m *Monitor) checkMySQL(site *Site) error {
 	FROM information_schema.tables
 	LIMIT 1
 	`
-	var name string
-	ctx, cFunc := context.WithDeadline(context.Background(), time.Now().Add(time.Duration(site.TimeoutMillis)*time.Millisecond))
-	defer cFunc()
-
 	tb := time.Now()
-	err = db.GetContext(ctx, &name, q)
+	mc := &site.MySQLConfig
+	phase, class, err := queryDB(ctx, site, db, q, newDBLimits(site, mc.Retries, mc.ConnectTimeoutMillis, mc.QueryTimeoutMillis))
 	if err != nil {
 		zLog.Error(site.Protocol,
+			zap.String("phase", phase),
+			zap.String("class", class),
 			zap.String("error", err.Error()))
-		return fmt.Errorf("action: query database, err: %s", err.Error())
+		return fmt.Errorf("action: query database, phase: %s, class: %s, err: %s", phase, class, err.Error())
 	}
 	te := time.Now()
 
+	total := te.Sub(tb).Milliseconds()
 	zLog.Info(site.Protocol,
 		zap.String("server", site.Server),
-		zap.Int64("total", te.Sub(tb).Milliseconds()))
+		zap.Int64("total", total))
+
+	// Warn about a slow, albeit successful, query.
+	if th := site.MySQLConfig.SlowThresholdMillis; th > 0 && total >= th {
+		zLog.Warn(site.Protocol,
+			zap.String("server", site.Server),
+			zap.Int64("total", total),
+			zap.Int64("threshold", th))
+		sErr := fmt.Errorf("query time limit (%d) exceeded: %d ms", th, total)
+		m.alert(site, "slow query", sErr)
+	}
 	return nil
 }
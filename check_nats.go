@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+// checkNATS connects to the given NATS server, as per the given
+// specification, and performs a round trip to it.
+func (m *Monitor) checkNATS(ctx context.Context, site *Site) error {
+	// Connection setup.
+	timeout := time.Duration(site.TimeoutMillis) * time.Millisecond
+	opts := []nats.Option{
+		nats.Name("HeartBeat"),
+		nats.Timeout(timeout),
+		nats.NoReconnect(),
+	}
+	if site.NATSConfig.Username != "" {
+		opts = append(opts, nats.UserInfo(site.NATSConfig.Username, site.NATSConfig.Password))
+	}
+	if site.NATSConfig.Token != "" {
+		opts = append(opts, nats.Token(site.NATSConfig.Token))
+	}
+
+	tb := time.Now()
+	nc, err := nats.Connect(fmt.Sprintf("nats://%s:%d", site.Server, site.NATSConfig.Port), opts...)
+	if err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: connect to server, err: %s", err.Error())
+	}
+	defer nc.Close()
+	tConnect := time.Since(tb).Milliseconds()
+	tf := time.Now()
+	tracePhase(ctx, "connect", tb, tf)
+
+	// Flush, so that a round trip to the server is made.
+	fctx, cFunc := context.WithTimeout(ctx, timeout-time.Since(tb))
+	defer cFunc()
+	if err = nc.FlushWithContext(fctx); err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: flush, err: %s", err.Error())
+	}
+	tracePhase(ctx, "flush", tf, time.Now())
+
+	zLog.Info(site.Protocol,
+		zap.String("server", site.Server),
+		zap.Int64("connect", tConnect),
+		zap.Int64("flush", time.Since(tf).Milliseconds()),
+		zap.Int64("total", time.Since(tb).Milliseconds()))
+	return nil
+}
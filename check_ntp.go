@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/beevik/ntp"
+	"go.uber.org/zap"
+)
+
+// checkNTP queries the given NTP server, as per the given specification,
+// and compares the offset of the local clock from the server's to the
+// configured threshold.
+func (m *Monitor) checkNTP(ctx context.Context, site *Site) error {
+	nc := &site.NTPConfig
+	port := nc.Port
+	if port == 0 {
+		port = DefNTPPort
+	}
+	addr := net.JoinHostPort(site.Server, strconv.Itoa(port))
+
+	tb := time.Now()
+	resp, err := ntp.QueryWithOptions(addr, ntp.QueryOptions{
+		Timeout:      time.Duration(site.TimeoutMillis) * time.Millisecond,
+		LocalAddress: m.sourceAddress(site),
+	})
+	tracePhase(ctx, "query", tb, time.Now())
+	if err == nil {
+		err = resp.Validate()
+	}
+	if err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("uri", site.Server),
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: query time, err: %s", err.Error())
+	}
+
+	offset := resp.ClockOffset.Milliseconds()
+	zLog.Info(site.Protocol,
+		zap.String("server", site.Server),
+		zap.Int("stratum", int(resp.Stratum)),
+		zap.Int64("offset", offset),
+		zap.Int64("delay", resp.RTT.Milliseconds()))
+
+	if offset < 0 {
+		offset = -offset
+	}
+	if limit := nc.MaxOffsetMillis; limit > 0 && offset > limit {
+		return fmt.Errorf("action: compare clocks, err: offset %s exceeds %d ms", resp.ClockOffset, limit)
+	}
+	return nil
+}
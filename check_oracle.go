@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	go_ora "github.com/sijms/go-ora/v2"
+	"go.uber.org/zap"
+)
+
+// oracleDSN answers the connection string for the given site.  Oracle
+// identifies the database by service name or, in older set-ups, by SID;
+// both are escaped as necessary by the driver.
+func oracleDSN(site *Site) string {
+	oc := &site.OracleConfig
+	var opts map[string]string
+	if oc.SID != "" {
+		opts = map[string]string{"SID": oc.SID}
+	}
+	return go_ora.BuildUrl(site.Server, oc.Port, oc.ServiceName, oc.Username, oc.Password, opts)
+}
+
+// checkOracle makes a connection request to the given server, as per the
+// given specification.
+func (m *Monitor) checkOracle(ctx context.Context, site *Site) error {
+	// Connection setup.
+	conn := go_ora.NewConnector(oracleDSN(site)).(*go_ora.OracleConnector)
+	if m.sourceAddress(site) != "" {
+		conn.Dialer(m.dialer(site, 0))
+	}
+	db := sqlx.NewDb(sql.OpenDB(conn), "oracle")
+	defer db.Close()
+
+	// Execute query, so that an actual connection is made.
+	q := `SELECT 1 FROM DUAL`
+	tb := time.Now()
+	oc := &site.OracleConfig
+	phase, class, err := queryDB(ctx, site, db, q, newDBLimits(site, oc.Retries, oc.ConnectTimeoutMillis, oc.QueryTimeoutMillis))
+	if err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("phase", phase),
+			zap.String("class", class),
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: query database, phase: %s, class: %s, err: %s", phase, class, err.Error())
+	}
+	te := time.Now()
+
+	total := te.Sub(tb).Milliseconds()
+	zLog.Info(site.Protocol,
+		zap.String("server", site.Server),
+		zap.Int64("total", total))
+
+	// Warn about a slow, albeit successful, query.
+	if th := site.OracleConfig.SlowThresholdMillis; th > 0 && total >= th {
+		zLog.Warn(site.Protocol,
+			zap.String("server", site.Server),
+			zap.Int64("total", total),
+			zap.Int64("threshold", th))
+		sErr := fmt.Errorf("query time limit (%d) exceeded: %d ms", th, total)
+		m.alert(site, "slow query", sErr)
+	}
+	return nil
+}
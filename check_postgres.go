@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// checkPostgres makes a connection request to the given server, as per
+// the given specification.  Optionally, it checks the replication lag of
+// a read replica.
+func (m *Monitor) checkPostgres(ctx context.Context, site *Site) error {
+	// Connection setup.
+	pc := &site.PostgresConfig
+	query := url.Values{}
+	query.Add("application_name", "HeartBeat")
+	if pc.SSLMode != "" {
+		query.Add("sslmode", pc.SSLMode)
+	}
+
+	u := &url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(pc.Username, pc.Password),
+		Host:     fmt.Sprintf("%s:%d", site.Server, pc.Port),
+		Path:     "/" + pc.Database,
+		RawQuery: query.Encode(),
+	}
+	conn, err := pq.NewConnector(u.String())
+	if err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: connect to database, err: %s", err.Error())
+	}
+	if m.sourceAddress(site) != "" {
+		conn.Dialer(pqDialer{m.dialer(site, 0)})
+	}
+	db := sqlx.NewDb(sql.OpenDB(conn), "postgres")
+	defer db.Close()
+
+	// Execute query, so that an actual connection is made.
+	q := `
+	SELECT table_name
+	FROM information_schema.tables
+	LIMIT 1
+	`
+	tb := time.Now()
+	phase, class, err := queryDB(ctx, site, db, q, newDBLimits(site, 0, 0, 0))
+	if err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("phase", phase),
+			zap.String("class", class),
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: query database, phase: %s, class: %s, err: %s", phase, class, err.Error())
+	}
+	te := time.Now()
+
+	zLog.Info(site.Protocol,
+		zap.String("server", site.Server),
+		zap.Int64("total", te.Sub(tb).Milliseconds()))
+
+	if pc.MaxReplicationLagSeconds > 0 {
+		return m.checkReplicationLag(ctx, site, db)
+	}
+	return nil
+}
+
+// checkReplicationLag measures the time since the last transaction was
+// replayed on the given replica, and compares it with the configured
+// maximum.
+func (m *Monitor) checkReplicationLag(ctx context.Context, site *Site, db *sqlx.DB) error {
+	q := `
+	SELECT EXTRACT(EPOCH FROM (now() - pg_last_xact_replay_timestamp()))
+	`
+	var lag sql.NullFloat64
+	ctx, cFunc := context.WithTimeout(ctx, time.Duration(site.TimeoutMillis)*time.Millisecond)
+	defer cFunc()
+
+	if err := db.GetContext(ctx, &lag, q); err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: query replication lag, err: %s", err.Error())
+	}
+	if !lag.Valid {
+		zLog.Error(site.Protocol,
+			zap.String("server", site.Server),
+			zap.String("error", "not a replica"))
+		return fmt.Errorf("action: query replication lag, err: server is not a replica, or has replayed nothing")
+	}
+
+	zLog.Info(site.Protocol,
+		zap.String("server", site.Server),
+		zap.Float64("replicationLag", lag.Float64))
+	if max := site.PostgresConfig.MaxReplicationLagSeconds; lag.Float64 > max {
+		sErr := fmt.Errorf("replication lag limit (%g s) exceeded: %.1f s", max, lag.Float64)
+		m.alert(site, "replication", sErr)
+	}
+	return nil
+}
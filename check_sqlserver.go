@@ -2,34 +2,59 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"net/url"
 	"time"
 
-	_ "github.com/denisenkom/go-mssqldb"
+	mssql "github.com/denisenkom/go-mssqldb"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 )
 
-// checkSQLServer makes a connection request to the given server, as per
-// the given specification.
-func (m *Monitor) checkSQLServer(site *Site) error {
-	// Connection setup.
+// sqlServerDSN answers the connection string for the given site.  A
+// named instance is resolved by the SQL Server Browser service when no
+// port is specified.
+func sqlServerDSN(site *Site) string {
+	sc := &site.SQLServerConfig
 	query := url.Values{}
 	query.Add("app name", "HeartBeat")
+	if sc.Encrypt != "" {
+		query.Add("encrypt", sc.Encrypt)
+	}
+	if sc.TrustServerCertificate {
+		query.Add("TrustServerCertificate", "true")
+	}
 
 	u := &url.URL{
 		Scheme:   "sqlserver",
-		User:     url.UserPassword(site.SQLServerConfig.Username, site.SQLServerConfig.Password),
-		Host:     fmt.Sprintf("%s:%d", site.Server, site.SQLServerConfig.Port),
+		User:     url.UserPassword(sc.Username, sc.Password),
+		Host:     site.Server,
 		RawQuery: query.Encode(),
 	}
-	db, err := sqlx.Open("sqlserver", u.String())
+	if sc.Port != 0 {
+		u.Host = fmt.Sprintf("%s:%d", site.Server, sc.Port)
+	}
+	if sc.Instance != "" {
+		u.Path = sc.Instance
+	}
+	return u.String()
+}
+
+// checkSQLServer makes a connection request to the given server, as per
+// the given specification.
+func (m *Monitor) checkSQLServer(ctx context.Context, site *Site) error {
+	// Connection setup.
+	conn, err := mssql.NewConnector(sqlServerDSN(site))
 	if err != nil {
 		zLog.Error(site.Protocol,
 			zap.String("error", err.Error()))
 		return fmt.Errorf("action: connect to database, err: %s", err.Error())
 	}
+	if m.sourceAddress(site) != "" {
+		conn.Dialer = m.dialer(site, 0)
+	}
+	db := sqlx.NewDb(sql.OpenDB(conn), "sqlserver")
 	defer db.Close()
 
 	// Execute query, so that an actual connection is made.
@@ -37,21 +62,31 @@ func (m *Monitor) checkSQLServer(site *Site) error {
 	SELECT TOP 1 name
 	FROM sys.tables
 	`
-	var name string
-	ctx, cFunc := context.WithDeadline(context.Background(), time.Now().Add(time.Duration(site.TimeoutMillis)*time.Millisecond))
-	defer cFunc()
-
 	tb := time.Now()
-	err = db.GetContext(ctx, &name, q)
+	sc := &site.SQLServerConfig
+	phase, class, err := queryDB(ctx, site, db, q, newDBLimits(site, sc.Retries, sc.ConnectTimeoutMillis, sc.QueryTimeoutMillis))
 	if err != nil {
 		zLog.Error(site.Protocol,
+			zap.String("phase", phase),
+			zap.String("class", class),
 			zap.String("error", err.Error()))
-		return fmt.Errorf("action: query database, err: %s", err.Error())
+		return fmt.Errorf("action: query database, phase: %s, class: %s, err: %s", phase, class, err.Error())
 	}
 	te := time.Now()
 
+	total := te.Sub(tb).Milliseconds()
 	zLog.Info(site.Protocol,
 		zap.String("server", site.Server),
-		zap.Int64("total", te.Sub(tb).Milliseconds()))
+		zap.Int64("total", total))
+
+	// Warn about a slow, albeit successful, query.
+	if th := site.SQLServerConfig.SlowThresholdMillis; th > 0 && total >= th {
+		zLog.Warn(site.Protocol,
+			zap.String("server", site.Server),
+			zap.Int64("total", total),
+			zap.Int64("threshold", th))
+		sErr := fmt.Errorf("query time limit (%d) exceeded: %d ms", th, total)
+		m.alert(site, "slow query", sErr)
+	}
 	return nil
 }
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSQLServerDSN(t *testing.T) {
+	tests := []struct {
+		name string
+		conf SQLServerConfig
+		want string
+	}{
+		{"default", SQLServerConfig{}, "sqlserver://sa:p%40ss@db?app+name=HeartBeat"},
+		{"port", SQLServerConfig{Port: 1433}, "sqlserver://sa:p%40ss@db:1433?app+name=HeartBeat"},
+		{"named instance", SQLServerConfig{Instance: "SQLEXPRESS"}, "sqlserver://sa:p%40ss@db/SQLEXPRESS?app+name=HeartBeat"},
+		{"encryption", SQLServerConfig{Encrypt: "true", TrustServerCertificate: true},
+			"sqlserver://sa:p%40ss@db?TrustServerCertificate=true&app+name=HeartBeat&encrypt=true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := &Site{Protocol: "sqlserver", Server: "db", SQLServerConfig: tt.conf}
+			site.SQLServerConfig.Username = "sa"
+			site.SQLServerConfig.Password = "p@ss"
+			if got := sqlServerDSN(site); got != tt.want {
+				t.Fatalf("DSN %q, expected %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// checkSSH runs the configured command on the given server over SSH, as
+// per the given specification.  The check fails if the command exits
+// with a non-zero status, or if its output does not match the expected
+// pattern, if any.
+func (m *Monitor) checkSSH(ctx context.Context, site *Site) error {
+	sc := &site.SSHConfig
+	timeout := time.Duration(site.TimeoutMillis) * time.Millisecond
+
+	// Authentication setup.
+	var auth []ssh.AuthMethod
+	if sc.KeyFile != "" {
+		buf, err := os.ReadFile(sc.KeyFile)
+		if err != nil {
+			return fmt.Errorf("action: read key file, err: %s", err.Error())
+		}
+		signer, err := ssh.ParsePrivateKey(buf)
+		if err != nil {
+			return fmt.Errorf("action: parse key file, err: %s", err.Error())
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if sc.Password != "" {
+		auth = append(auth, ssh.Password(sc.Password))
+	}
+	hostKeyCallback := ssh.InsecureIgnoreHostKey()
+	if sc.KnownHostsFile != "" {
+		cb, err := knownhosts.New(sc.KnownHostsFile)
+		if err != nil {
+			return fmt.Errorf("action: read known hosts, err: %s", err.Error())
+		}
+		hostKeyCallback = cb
+	}
+	conf := &ssh.ClientConfig{
+		User:            sc.Username,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         timeout,
+	}
+
+	// Connect.
+	tb := time.Now()
+	addr := net.JoinHostPort(site.Server, strconv.Itoa(sc.Port))
+	cl, err := ssh.Dial("tcp", addr, conf)
+	if err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: connect to server, err: %s", err.Error())
+	}
+	defer cl.Close()
+	tracePhase(ctx, "connect", tb, time.Now())
+	sess, err := cl.NewSession()
+	if err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: open session, err: %s", err.Error())
+	}
+	defer sess.Close()
+
+	// Run the command, bounded by the timeout.  Closing the client
+	// unblocks a command that is still running.
+	type result struct {
+		out []byte
+		err error
+	}
+	tc := time.Now()
+	ch := make(chan result, 1)
+	go func() {
+		out, err := sess.CombinedOutput(sc.Command)
+		ch <- result{out, err}
+	}()
+	var res result
+	select {
+	case res = <-ch:
+		// Intentionally left blank.
+
+	case <-time.After(timeout - time.Since(tb)):
+		cl.Close()
+		zLog.Error(site.Protocol,
+			zap.String("command", sc.Command),
+			zap.String("error", "command timed out"))
+		return fmt.Errorf("action: run command, err: timed out after %d ms", site.TimeoutMillis)
+
+	case <-ctx.Done():
+		cl.Close()
+		return fmt.Errorf("action: run command, err: %s", ctx.Err().Error())
+	}
+	tCommand := time.Since(tc).Milliseconds()
+	tracePhase(ctx, "command", tc, time.Now())
+
+	if res.err != nil {
+		var exitErr *ssh.ExitError
+		if errors.As(res.err, &exitErr) {
+			zLog.Error(site.Protocol,
+				zap.String("command", sc.Command),
+				zap.Int("exitCode", exitErr.ExitStatus()),
+				zap.ByteString("output", res.out))
+			return fmt.Errorf("action: run command, exit code: %d, output: %s", exitErr.ExitStatus(), res.out)
+		}
+		zLog.Error(site.Protocol,
+			zap.String("command", sc.Command),
+			zap.String("error", res.err.Error()))
+		return fmt.Errorf("action: run command, err: %s", res.err.Error())
+	}
+	if sc.ExpectPattern != "" {
+		re, err := regexp.Compile(sc.ExpectPattern)
+		if err != nil {
+			return fmt.Errorf("%w: invalid expected pattern: %s", ErrConfig, err.Error())
+		}
+		if !re.Match(res.out) {
+			zLog.Error(site.Protocol,
+				zap.String("command", sc.Command),
+				zap.ByteString("output", res.out))
+			return fmt.Errorf("action: match output, err: output does not match '%s'", sc.ExpectPattern)
+		}
+	}
+
+	zLog.Info(site.Protocol,
+		zap.String("server", site.Server),
+		zap.Int64("command", tCommand),
+		zap.Int64("total", time.Since(tb).Milliseconds()))
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// checkTCP makes a TCP connection to each of the ports of the given
+// server, as per the given specification.  The site fails if any of the
+// ports cannot be connected to; or, if inverted, if any of them can.
+func (m *Monitor) checkTCP(ctx context.Context, site *Site) error {
+	ports := site.TCPConfig.Ports
+	if len(ports) == 0 {
+		ports = []int{site.TCPConfig.Port}
+	}
+
+	var errs []error
+	for _, port := range ports {
+		err := m.checkTCPPort(ctx, site, port)
+		if site.Invert {
+			err = invertResult(site, err)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("port %d : %w", port, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// invertsPerPort answers whether the check of the given site inverts the
+// outcome of each of its ports itself, so that every port must be closed.
+func (s *Site) invertsPerPort() bool {
+	return s.Invert && s.Protocol == "tcp"
+}
+
+// checkTCPPort makes a TCP connection to the given port of the given
+// server, within the connect timeout.  Optionally, it awaits data from
+// the server, within the read timeout.
+func (m *Monitor) checkTCPPort(ctx context.Context, site *Site, port int) error {
+	tc := &site.TCPConfig
+	addr := net.JoinHostPort(site.Server, strconv.Itoa(port))
+	timeout := tc.ConnectTimeoutMillis
+	if timeout <= 0 {
+		timeout = site.TimeoutMillis
+	}
+
+	tb := time.Now()
+	conn, err := m.dialer(site, time.Duration(timeout)*time.Millisecond).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		zLog.Error(site.Protocol,
+			zap.String("uri", addr),
+			zap.String("phase", "connect"),
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: connect, err: %s", err.Error())
+	}
+	defer conn.Close()
+	tConnect := time.Since(tb).Milliseconds()
+	tracePhase(ctx, "connect", tb, time.Now())
+
+	var tRead int64
+	if tc.ReadTimeoutMillis > 0 {
+		tr := time.Now()
+		conn.SetReadDeadline(tr.Add(time.Duration(tc.ReadTimeoutMillis) * time.Millisecond))
+		buf := make([]byte, 1)
+		if _, err = conn.Read(buf); err != nil {
+			zLog.Error(site.Protocol,
+				zap.String("uri", addr),
+				zap.String("phase", "read"),
+				zap.String("error", err.Error()))
+			return fmt.Errorf("action: read, err: %s", err.Error())
+		}
+		tRead = time.Since(tr).Milliseconds()
+		tracePhase(ctx, "read", tr, time.Now())
+	}
+
+	zLog.Info(site.Protocol,
+		zap.String("uri", addr),
+		zap.Int64("connect", tConnect),
+		zap.Int64("read", tRead))
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestTCPPorts(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	open := l.Addr().(*net.TCPAddr).Port
+	_, closed := unreachableAddr(t)
+	_, closed2 := unreachableAddr(t)
+
+	tests := []struct {
+		name   string
+		ports  []int
+		invert bool
+		up     bool
+		failed int // port named in the error
+	}{
+		{"all open", []int{open, open}, false, true, 0},
+		{"one closed", []int{open, closed}, false, false, closed},
+		{"all closed, inverted", []int{closed, closed2}, true, true, 0},
+		{"one open, inverted", []int{closed, open}, true, false, open},
+		{"all open, inverted", []int{open, open}, true, false, open},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := newTestMonitor(&Config{HeartbeatSeconds: 60})
+			site := Site{Protocol: "tcp", Server: "127.0.0.1", TCPConfig: TCPConfig{Ports: tt.ports}, TimeoutMillis: 500, Invert: tt.invert}
+
+			err := m.checkSite(site)
+			if up := err == nil; up != tt.up {
+				t.Fatalf("up %v, expected %v; err: %v", up, tt.up, err)
+			}
+			if err != nil && !strings.Contains(err.Error(), "port "+strconv.Itoa(tt.failed)+" :") {
+				t.Fatalf("port %d not named: %v", tt.failed, err)
+			}
+		})
+	}
+}
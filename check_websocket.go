@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// checkWebSocket performs a WebSocket handshake with the given server, as
+// per the given specification.  Optionally, it sends a ping frame, and
+// waits for the corresponding pong.
+func (m *Monitor) checkWebSocket(ctx context.Context, site *Site) error {
+	// Construct the full URL.  `websocket` is treated as an alias of `ws`.
+	scheme := site.Protocol
+	if scheme == "websocket" {
+		scheme = "ws"
+	}
+	host := site.Server
+	if site.WebSocketConfig.Port != 0 {
+		host = fmt.Sprintf("%s:%d", site.Server, site.WebSocketConfig.Port)
+	}
+	u := &url.URL{
+		Scheme: scheme,
+		Host:   host,
+		Path:   "/" + site.WebSocketConfig.Path,
+	}
+
+	timeout := time.Duration(site.TimeoutMillis) * time.Millisecond
+	dialer := &websocket.Dialer{
+		HandshakeTimeout: timeout,
+		TLSClientConfig:  &tls.Config{InsecureSkipVerify: !m.verifyCert(site.WebSocketConfig.VerifyCert)},
+	}
+	if site.WebSocketConfig.Subprotocol != "" {
+		dialer.Subprotocols = []string{site.WebSocketConfig.Subprotocol}
+	}
+
+	// Perform the handshake.
+	tb := time.Now()
+	conn, resp, err := dialer.DialContext(ctx, u.String(), nil)
+	if err != nil {
+		if resp != nil {
+			zLog.Error(site.Protocol,
+				zap.String("uri", site.Server),
+				zap.Int("status", resp.StatusCode),
+				zap.String("error", err.Error()))
+			return fmt.Errorf("action: websocket handshake, status: %d, err: %s", resp.StatusCode, err.Error())
+		}
+		zLog.Error(site.Protocol,
+			zap.String("uri", site.Server),
+			zap.String("error", err.Error()))
+		return fmt.Errorf("action: websocket handshake, err: %s", err.Error())
+	}
+	defer conn.Close()
+	tHandshake := time.Since(tb).Milliseconds()
+	tracePhase(ctx, "handshake", tb, time.Now())
+
+	// Send a ping, and wait for the pong, if asked for.
+	if site.WebSocketConfig.Ping {
+		deadline := tb.Add(timeout)
+		pong := make(chan struct{}, 1)
+		conn.SetPongHandler(func(string) error {
+			pong <- struct{}{}
+			// Unblock the pending read below.
+			return conn.SetReadDeadline(time.Now())
+		})
+		if err = conn.WriteControl(websocket.PingMessage, []byte("heartbeat"), deadline); err != nil {
+			zLog.Error(site.Protocol,
+				zap.String("uri", site.Server),
+				zap.String("error", err.Error()))
+			return fmt.Errorf("action: websocket ping, err: %s", err.Error())
+		}
+
+		// Control frames are processed only while reading; hence, read
+		// until the pong arrives or the deadline passes.
+		conn.SetReadDeadline(deadline)
+		for err == nil && len(pong) == 0 {
+			_, _, err = conn.ReadMessage()
+		}
+		if len(pong) == 0 {
+			zLog.Error(site.Protocol,
+				zap.String("uri", site.Server),
+				zap.String("error", err.Error()))
+			return fmt.Errorf("action: websocket pong, err: %s", err.Error())
+		}
+	}
+
+	// Close the connection cleanly.
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+
+	zLog.Info(site.Protocol,
+		zap.String("uri", site.Server),
+		zap.Int64("handshake", tHandshake),
+		zap.Int64("total", time.Since(tb).Milliseconds()))
+	return nil
+}
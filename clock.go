@@ -0,0 +1,69 @@
+package main
+
+import (
+	"time"
+)
+
+// Clock abstracts the passage of time, so that scheduling and
+// time-based thresholds can be exercised deterministically.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+	After(d time.Duration) <-chan time.Time
+}
+
+// Ticker abstracts `time.Ticker`.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// realClock is the Clock backed by the `time` package.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// realTicker wraps a `time.Ticker`.
+type realTicker struct {
+	t *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t realTicker) Stop() {
+	t.t.Stop()
+}
+
+// now answers the current time, as per the monitor's clock.
+func (m *Monitor) now() time.Time {
+	if m.clock == nil {
+		return time.Now()
+	}
+	return m.clock.Now()
+}
+
+// since answers the time elapsed since the given time, as per the
+// monitor's clock.
+func (m *Monitor) since(t time.Time) time.Duration {
+	return m.now().Sub(t)
+}
+
+// localTime answers the given time in the configured display timezone.
+func (m *Monitor) localTime(t time.Time) time.Time {
+	if m.location == nil {
+		return t
+	}
+	return t.In(m.location)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that advances only when told to.  Timers and
+// tickers fire as the clock is advanced past their deadlines.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	timers  []fakeTimer
+	tickers []*fakeTicker
+}
+
+type fakeTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock(t time.Time) *fakeClock {
+	return &fakeClock{now: t}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTicker{ch: make(chan time.Time, 1), period: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.timers = append(c.timers, fakeTimer{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward by the given duration, firing the
+// timers and tickers that fall due.  A ticker whose channel is full drops
+// ticks, as `time.Ticker` does.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			pending = append(pending, t)
+			continue
+		}
+		t.ch <- c.now
+	}
+	c.timers = pending
+
+	for _, t := range c.tickers {
+		for !t.stopped && !t.next.After(c.now) {
+			select {
+			case t.ch <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.period)
+		}
+	}
+}
+
+// Waiters answers the number of timers yet to fire.
+func (c *fakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+type fakeTicker struct {
+	ch      chan time.Time
+	period  time.Duration
+	next    time.Time
+	stopped bool
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTicker) Stop() {
+	t.stopped = true
+}
+
+func TestFakeClock(t *testing.T) {
+	clk := newFakeClock(testEpoch)
+	after := clk.After(2 * time.Second)
+	ticker := clk.NewTicker(time.Second)
+
+	clk.Advance(time.Second)
+	select {
+	case <-after:
+		t.Fatal("timer fired early")
+	default:
+	}
+	if got := <-ticker.C(); !got.Equal(testEpoch.Add(time.Second)) {
+		t.Fatalf("tick at %v, expected %v", got, testEpoch.Add(time.Second))
+	}
+
+	clk.Advance(time.Second)
+	if got := <-after; !got.Equal(testEpoch.Add(2 * time.Second)) {
+		t.Fatalf("timer fired at %v, expected %v", got, testEpoch.Add(2*time.Second))
+	}
+	ticker.Stop()
+	<-ticker.C()
+	clk.Advance(time.Second)
+	select {
+	case <-ticker.C():
+		t.Fatal("stopped ticker ticked")
+	default:
+	}
+}
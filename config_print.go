@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// redacted replaces the values of secrets in the printed configuration.
+const redacted = "********"
+
+// print writes the configuration to the given writer as indented JSON,
+// with passwords, tokens and keys redacted.
+func (c *Config) print(w io.Writer) error {
+	buf, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	// Work on a generic copy, so that secrets in configuration sections
+	// added later are redacted as well.
+	var v interface{}
+	if err = json.Unmarshal(buf, &v); err != nil {
+		return err
+	}
+	redactSecrets(v)
+
+	buf, err = json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(buf, '\n'))
+	return err
+}
+
+// redactSecrets replaces, in place, every non-empty string value whose key
+// names a password, token, secret or key.
+func redactSecrets(v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if s, ok := e.(string); ok && s != "" && isSecretKey(k) {
+				v[k] = redacted
+				continue
+			}
+			redactSecrets(e)
+		}
+
+	case []interface{}:
+		for _, e := range v {
+			redactSecrets(e)
+		}
+	}
+}
+
+// isSecretKey answers whether the given JSON key holds a secret.
+func isSecretKey(k string) bool {
+	k = strings.ToLower(k)
+	for _, s := range []string{"password", "token", "secret"} {
+		if strings.Contains(k, s) && !strings.HasSuffix(k, "file") {
+			return true
+		}
+	}
+	// As in `routingKey` or `apiKey`; not `keyFile` or `keyspace`.
+	return strings.HasSuffix(k, "key")
+}
+
+// effectiveConfig answers a copy of the monitor's configuration, in which
+// the sites' timeouts are resolved as their checks resolve them.
+func (m *Monitor) effectiveConfig() *Config {
+	conf := *m.conf
+	conf.Sites = append([]Site(nil), m.conf.Sites...)
+	for i := range conf.Sites {
+		site := &conf.Sites[i]
+		family := protocolFamilies[site.Protocol]
+		m.setDefaultTimeout(site, family, builtinTimeoutMillis(family))
+	}
+	return &conf
+}
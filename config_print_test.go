@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPrintEffectiveConfig(t *testing.T) {
+	m, _ := newTestMonitor(&Config{
+		Defaults: map[string]int64{"sqlserver": 15000},
+		Notifiers: []NotifierConfig{
+			{Name: "pd", Type: "pagerduty", PagerDuty: PagerDutyConfig{RoutingKey: "R0UT1NG"}},
+		},
+		Sites: []Site{
+			{Protocol: "sqlserver", Server: "db"},
+			{Protocol: "tcp", Server: "h"},
+			{Protocol: "ssh", Server: "h", TimeoutMillis: 750},
+		},
+	})
+
+	var buf bytes.Buffer
+	if err := m.effectiveConfig().print(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{`"timeoutMillis": 15000`, `"timeoutMillis": 500`, `"timeoutMillis": 750`, `"routingKey": "` + redacted + `"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("%s not printed", want)
+		}
+	}
+	if strings.Contains(out, "R0UT1NG") {
+		t.Error("routing key printed in clear")
+	}
+	if m.conf.Sites[0].TimeoutMillis != 0 {
+		t.Error("configured site altered")
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+)
+
+// isNamed answers whether the site is known by the given name: its own,
+// or that of the site from whose servers it was expanded.
+func (s *Site) isNamed(name string) bool {
+	return name != "" && (s.Name == name || s.origin == name)
+}
+
+// dependencies answers the indices of the given sites on which the given
+// site depends.  A dependency names either a site, or all the sites
+// expanded from its servers.
+func dependencies(sites []Site, site *Site) []int {
+	if site.DependsOn == "" {
+		return nil
+	}
+
+	var res []int
+	for i := range sites {
+		if sites[i].isNamed(site.DependsOn) {
+			res = append(res, i)
+		}
+	}
+	return res
+}
+
+// validateDependencies checks that the dependencies of the given sites
+// name known sites, and are free of cycles.
+func validateDependencies(sites []Site) error {
+	for i := range sites {
+		if s := &sites[i]; s.DependsOn != "" && len(dependencies(sites, s)) == 0 {
+			return fmt.Errorf("%w: site '%s' : unknown dependency: %s (not the name of a site)", ErrConfig, s.key(), s.DependsOn)
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make([]int, len(sites))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch marks[i] {
+		case visiting:
+			return fmt.Errorf("%w: dependency cycle through: %s", ErrConfig, sites[i].key())
+		case visited:
+			return nil
+		}
+		marks[i] = visiting
+		for _, j := range dependencies(sites, &sites[i]) {
+			if err := visit(j); err != nil {
+				return err
+			}
+		}
+		marks[i] = visited
+		return nil
+	}
+	for i := range sites {
+		if err := visit(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// failingDependency answers the root of the failing dependencies of the
+// given site, if any: the farthest failing site along its dependencies.
+func (m *Monitor) failingDependency(site *Site) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	root := ""
+	seen := map[string]bool{site.key(): true}
+	var walk func(s *Site)
+	walk = func(s *Site) {
+		for _, i := range dependencies(m.conf.Sites, s) {
+			d := &m.conf.Sites[i]
+			if seen[d.key()] {
+				continue
+			}
+			seen[d.key()] = true
+			if st, ok := m.states[d.key()]; ok && st.consecutiveFailures > 0 {
+				root = d.key()
+			}
+			walk(d)
+		}
+	}
+	walk(site)
+	return root
+}
+
+// dependencyLevels partitions the given sites into successive levels,
+// such that a site is checked only after its dependencies, should they
+// be due in the same sweep.
+func dependencyLevels(sites []Site) [][]Site {
+	levels := make([]int, len(sites))
+	for i := range levels {
+		levels[i] = -1
+	}
+	var level func(i, depth int) int
+	level = func(i, depth int) int {
+		if levels[i] >= 0 {
+			return levels[i]
+		}
+		l := 0
+		if depth <= len(sites) {
+			for _, j := range dependencies(sites, &sites[i]) {
+				l = max(l, level(j, depth+1)+1)
+			}
+		}
+		levels[i] = l
+		return l
+	}
+
+	var res [][]Site
+	for i := range sites {
+		l := level(i, 0)
+		for len(res) <= l {
+			res = append(res, nil)
+		}
+		res[l] = append(res[l], sites[i])
+	}
+	return res
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateDependencies(t *testing.T) {
+	tcp := func(name, dependsOn string, servers ...string) Site {
+		return Site{Name: name, Protocol: "tcp", Server: "h", Servers: servers, TCPConfig: TCPConfig{Port: 22}, DependsOn: dependsOn}
+	}
+
+	tests := []struct {
+		name  string
+		sites []Site
+		err   string
+	}{
+		{"named", []Site{tcp("db", ""), tcp("app", "db")}, ""},
+		{"expanded", []Site{tcp("db", "", "h1", "h2"), tcp("app", "db")}, ""},
+		{"unknown", []Site{tcp("app", "db")}, "unknown dependency"},
+		{"by key", []Site{tcp("", ""), tcp("app", "tcp://h:22/")}, "unknown dependency"},
+		{"cycle", []Site{tcp("a", "b"), tcp("b", "a")}, "dependency cycle"},
+		{"on itself, expanded", []Site{tcp("db", "db", "h1", "h2")}, "dependency cycle"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{Sites: tt.sites}
+			err := c.validate()
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Fatalf("expected %q; got %v", tt.err, err)
+			case tt.err != "" && !errors.Is(err, ErrConfig):
+				t.Fatalf("not a configuration error: %v", err)
+			}
+		})
+	}
+}
+
+func TestFailingDependency(t *testing.T) {
+	sites := expandServers([]Site{
+		{Name: "db", Protocol: "tcp", Servers: []string{"h1", "h2"}},
+		{Name: "cache", Protocol: "tcp", Server: "c", DependsOn: "db"},
+		{Name: "app", Protocol: "tcp", Server: "a", DependsOn: "cache"},
+	})
+	m, _ := newTestMonitor(&Config{HeartbeatSeconds: 60, Sites: sites})
+	app := &m.conf.Sites[3]
+
+	if root := m.failingDependency(app); root != "" {
+		t.Fatalf("dependency %s failing, with none checked", root)
+	}
+	m.recordResult(&m.conf.Sites[2], errors.New("down"))
+	if root := m.failingDependency(app); root != "cache" {
+		t.Fatalf("root %q, expected cache", root)
+	}
+	m.recordResult(&m.conf.Sites[1], errors.New("down"))
+	if root := m.failingDependency(app); root != "db@h2" {
+		t.Fatalf("root %q, expected db@h2", root)
+	}
+
+	levels := dependencyLevels(m.conf.Sites)
+	var got []string
+	for _, level := range levels {
+		var names []string
+		for _, s := range level {
+			names = append(names, s.Name)
+		}
+		got = append(got, strings.Join(names, ","))
+	}
+	if want := "db@h1,db@h2|cache|app"; strings.Join(got, "|") != want {
+		t.Fatalf("levels %s, expected %s", strings.Join(got, "|"), want)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// digestRow is the summary of a site over a digest period.
+type digestRow struct {
+	site         string
+	checks       int
+	availability float64 // percent
+	avgMillis    int64
+	alerts       int
+}
+
+// recordDigest accumulates the outcome and duration of a check of the
+// given site into the current digest period.
+func (m *Monitor) recordDigest(site *Site, err error, ms int64) {
+	if m.conf.DigestIntervalHours <= 0 {
+		return
+	}
+	m.withState(site, func(st *siteState) {
+		st.digestChecks++
+		if err == nil {
+			st.digestUp++
+		}
+		st.digestMillis += ms
+	})
+}
+
+// countDigestAlert counts an alert of the given site into the current
+// digest period.
+func (m *Monitor) countDigestAlert(site *Site) {
+	if m.conf.DigestIntervalHours <= 0 {
+		return
+	}
+	m.withState(site, func(st *siteState) {
+		st.digestAlerts++
+	})
+}
+
+// digestRows answers the summaries of all the configured sites over the
+// current digest period, and resets the period.
+func (m *Monitor) digestRows() []digestRow {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rows := make([]digestRow, 0, len(m.conf.Sites))
+	for i := range m.conf.Sites {
+		site := &m.conf.Sites[i]
+		row := digestRow{site: site.key()}
+		if st, ok := m.states[site.key()]; ok {
+			row.checks, row.alerts = st.digestChecks, st.digestAlerts
+			if st.digestChecks > 0 {
+				row.availability = 100 * float64(st.digestUp) / float64(st.digestChecks)
+				row.avgMillis = st.digestMillis / int64(st.digestChecks)
+			}
+			st.digestChecks, st.digestUp, st.digestMillis, st.digestAlerts = 0, 0, 0, 0
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// digestDeferredAlerts answers the alerts deferred outside alert hours
+// over the current digest period, and resets the period.
+func (m *Monitor) digestDeferredAlerts() []*Alert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deferred := m.digestDeferred
+	m.digestDeferred = nil
+	return deferred
+}
+
+// sendDigest sends the summary digest, if one is due, and begins a new
+// digest period.
+func (m *Monitor) sendDigest() {
+	if m.conf.DigestIntervalHours <= 0 {
+		return
+	}
+	now := m.now()
+	if m.digestFrom.IsZero() {
+		m.digestFrom = now
+		return
+	}
+	if now.Sub(m.digestFrom) < time.Duration(m.conf.DigestIntervalHours)*time.Hour {
+		return
+	}
+
+	from := m.digestFrom
+	m.digestFrom = now
+	recipients := m.conf.DigestRecipients
+	if len(recipients) == 0 {
+		recipients = m.conf.AdminRecipients
+	}
+	if len(recipients) == 0 {
+		zLog.Warn("digest",
+			zap.String("error", "no recipients configured"))
+		return
+	}
+
+	if err := m.sendDigestMail(recipients, from, now, m.digestRows(), m.digestDeferredAlerts()); err != nil {
+		zLog.Error("digest",
+			zap.String("error", err.Error()))
+		return
+	}
+	zLog.Info("digest",
+		zap.Strings("recipients", recipients),
+		zap.Time("from", from),
+		zap.Time("to", now))
+}
+
+// sendDigestMail composes the digest of the given period, together with
+// the alerts deferred outside alert hours, and dispatches it using the
+// SMTP configuration given in the configuration.
+func (m *Monitor) sendDigestMail(recipients []string, from, to time.Time, rows []digestRow, deferred []*Alert) error {
+	// Construct email headers
+	headers := make(map[string]string)
+	headers["From"] = fmt.Sprintf("%s <%s>", m.conf.Sender.DisplayName, m.conf.Sender.Username)
+	headers["To"] = strings.Join(recipients, ",")
+	headers["Subject"] = withEnvironment(m.conf.Environment, "DIGEST : Heartbeat summary ["+m.hostname+"]")
+
+	// Build the plain-text and HTML bodies.
+	period := m.localTime(from).Format(time.RFC3339) + " to " + m.localTime(to).Format(time.RFC3339)
+	text := "Heartbeat summary\r\n" +
+		"\r\n" +
+		"Period : " + period + "\r\n" +
+		"Monitor : " + m.hostname + "\r\n" +
+		"\r\n"
+	body := `
+	<h3>Heartbeat summary</h3>
+	<p>Period : ` + period + `</p>
+	<p>Monitor : ` + html.EscapeString(m.hostname) + `</p>
+	`
+	body += "<table>\r\n<tr><th>Site</th><th>Checks</th><th>Availability (%)</th><th>Average (ms)</th><th>Alerts</th></tr>\r\n"
+	for _, r := range rows {
+		text += fmt.Sprintf("%s : %d checks, %.2f%% available, %d ms average, %d alerts\r\n",
+			r.site, r.checks, r.availability, r.avgMillis, r.alerts)
+		body += fmt.Sprintf("<tr><td>%s</td><td>%d</td><td>%.2f</td><td>%d</td><td>%d</td></tr>\r\n",
+			html.EscapeString(r.site), r.checks, r.availability, r.avgMillis, r.alerts)
+	}
+	body += "</table>\r\n"
+	if len(deferred) > 0 {
+		text += "\r\nDeferred alerts\r\n\r\n"
+		body += "<h4>Deferred alerts</h4>\r\n<ul>\r\n"
+		for _, a := range deferred {
+			line := fmt.Sprintf("%s : %s at %s : %s", a.Site.key(), a.Service, a.At.Format(time.RFC3339), a.Err.Error())
+			text += line + "\r\n"
+			body += "<li>" + html.EscapeString(line) + "</li>\r\n"
+		}
+		body += "</ul>\r\n"
+	}
+
+	// Build message
+	message, err := buildAlternative(headers, text, body)
+	if err != nil {
+		return err
+	}
+
+	// Send email
+	return m.sendOrSpool(smtpAuthPlain, recipients, message)
+}
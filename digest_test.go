@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDigestCountsDNSFailures(t *testing.T) {
+	m, _ := newTestMonitor(&Config{HeartbeatSeconds: 60, ReportDNS: true, DigestIntervalHours: 24})
+	m.resolver = failingResolver()
+	site := Site{Name: "a", Protocol: "tcp", Server: "absent.invalid", TCPConfig: TCPConfig{Port: 80}}
+
+	for i := 0; i < 3; i++ {
+		if err := m.checkSite(site); err == nil {
+			t.Fatal("expected a DNS failure")
+		}
+	}
+	m.withState(&site, func(st *siteState) {
+		if st.digestChecks != 3 || st.digestUp != 0 || st.digestAlerts != 3 {
+			t.Fatalf("%d checks, %d up, %d alerts; expected 3, 0, 3", st.digestChecks, st.digestUp, st.digestAlerts)
+		}
+	})
+}
+
+func TestDigestListsDeferredAlerts(t *testing.T) {
+	srv := newFakeSMTP(t)
+	m, clk := newTestMonitor(&Config{
+		HeartbeatSeconds:    60,
+		DigestIntervalHours: 24,
+		AdminRecipients:     []string{"ops@example.com"},
+		Sender:              SenderConfig{Server: "127.0.0.1", Username: "hb@example.com", Attempts: 1},
+	})
+	m.mailServer = srv.addr
+	m.notifiers = []Notifier{&recordingNotifier{name: "rec"}}
+	// The clock starts at 09:00 UTC.
+	site := &Site{Name: "a", Protocol: "tcp", Server: "h", AlertHours: &AlertHours{Start: "10:00", End: "18:00"}}
+
+	m.sendDigest()
+	m.alert(site, "tcp", errors.New("connection refused"))
+	clk.Advance(24 * time.Hour)
+	m.sendDigest()
+
+	msgs := srv.messages()
+	if len(msgs) != 1 {
+		t.Fatalf("%d digests sent, expected 1", len(msgs))
+	}
+	if !strings.Contains(msgs[0], "Deferred alerts") || !strings.Contains(msgs[0], "connection refused") {
+		t.Fatalf("deferred alert missing from the digest:\n%s", msgs[0])
+	}
+	if n := len(m.digestDeferredAlerts()); n != 0 {
+		t.Fatalf("%d deferred alerts carried into the next period", n)
+	}
+}
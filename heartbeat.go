@@ -1,16 +1,26 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"html"
+	"io"
+	"maps"
+	"math/rand/v2"
+	"mime/multipart"
+	"mime/quotedprintable"
 	"net"
 	"net/smtp"
+	"net/textproto"
 	"os"
 	"os/signal"
 	"path"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -20,12 +30,65 @@ import (
 const (
 	// DefResolverTimeoutMillis is used in case of no specification in config.
 	DefResolverTimeoutMillis = 500
+	// DefDNSRetryDelayMillis is used in case of no specification in config.
+	DefDNSRetryDelayMillis = 200
 	// DefHTTPTimeoutMillis is used in case of no specification in config.
 	DefHTTPTimeoutMillis = 500
+	// DefHTTPTotalTimeoutFactor bounds a whole HTTP exchange to this
+	// multiple of the site's timeout, in case of no specification in
+	// config.  The slack lets a slow response be told apart from a hung
+	// server.
+	DefHTTPTotalTimeoutFactor = 2
 	// DefMySQLTimeoutMillis is used in case of no specification in config.
 	DefMySQLTimeoutMillis = 500
 	// DefSQLServerTimeoutMillis is used in case of no specification in config.
 	DefSQLServerTimeoutMillis = 500
+	// DefWebSocketTimeoutMillis is used in case of no specification in config.
+	DefWebSocketTimeoutMillis = 500
+	// DefMQTTTimeoutMillis is used in case of no specification in config.
+	DefMQTTTimeoutMillis = 1000
+	// DefMemcachedTimeoutMillis is used in case of no specification in config.
+	DefMemcachedTimeoutMillis = 500
+	// DefNATSTimeoutMillis is used in case of no specification in config.
+	DefNATSTimeoutMillis = 500
+	// DefSSHTimeoutMillis is used in case of no specification in config.
+	DefSSHTimeoutMillis = 5000
+	// DefPostgresTimeoutMillis is used in case of no specification in config.
+	DefPostgresTimeoutMillis = 500
+	// DefClickHouseTimeoutMillis is used in case of no specification in config.
+	DefClickHouseTimeoutMillis = 500
+	// DefLDAPTimeoutMillis is used in case of no specification in config.
+	DefLDAPTimeoutMillis = 1000
+	// DefConsulTimeoutMillis is used in case of no specification in config.
+	DefConsulTimeoutMillis = 1000
+	// DefEtcdTimeoutMillis is used in case of no specification in config.
+	DefEtcdTimeoutMillis = 2000
+	// DefTCPTimeoutMillis is used in case of no specification in config.
+	DefTCPTimeoutMillis = 500
+	// DefAMQPTimeoutMillis is used in case of no specification in config.
+	DefAMQPTimeoutMillis = 1000
+	// DefCassandraTimeoutMillis is used in case of no specification in config.
+	DefCassandraTimeoutMillis = 2000
+	// DefDockerTimeoutMillis is used in case of no specification in config.
+	DefDockerTimeoutMillis = 1000
+	// DefKubernetesTimeoutMillis is used in case of no specification in config.
+	DefKubernetesTimeoutMillis = 2000
+	// DefGRPCTimeoutMillis is used in case of no specification in config.
+	DefGRPCTimeoutMillis = 1000
+	// DefOracleTimeoutMillis is used in case of no specification in config.
+	DefOracleTimeoutMillis = 2000
+	// DefNTPTimeoutMillis is used in case of no specification in config.
+	DefNTPTimeoutMillis = 1000
+	// DefNTPPort is used in case of no specification in config.
+	DefNTPPort = 123
+
+	// DefSMTPAttempts is used in case of no specification in config.
+	DefSMTPAttempts = 3
+	// DefLogDir is used in case of no specification in config.
+	DefLogDir = "log"
+
+	// MaxBodyBytes caps the portion of a response body that is read.
+	MaxBodyBytes = 1 << 20
 )
 
 //
@@ -42,55 +105,268 @@ var (
 // isServerUp makes a request to the given URL, as per the specified
 // protocol, and reports a non-nil error in case the server at the URL
 // does not respond within the timeout duration.
-func (m *Monitor) isServerUp(site *Site) error {
+func (m *Monitor) isServerUp(ctx context.Context, site *Site) error {
 	switch site.Protocol {
 	case "http", "https":
-		if site.TimeoutMillis == 0 {
-			site.TimeoutMillis = DefHTTPTimeoutMillis
-		}
-		return m.checkHTTPx(site)
+		m.setDefaultTimeout(site, "http", DefHTTPTimeoutMillis)
+		return m.checkHTTPSite(ctx, site)
 
 	case "mysql":
-		if site.TimeoutMillis == 0 {
-			site.TimeoutMillis = DefMySQLTimeoutMillis
-		}
-		return m.checkMySQL(site)
+		m.setDefaultTimeout(site, "mysql", DefMySQLTimeoutMillis)
+		return m.checkMySQL(ctx, site)
 
 	case "sqlserver":
-		if site.TimeoutMillis == 0 {
-			site.TimeoutMillis = DefSQLServerTimeoutMillis
-		}
-		return m.checkSQLServer(site)
+		m.setDefaultTimeout(site, "sqlserver", DefSQLServerTimeoutMillis)
+		return m.checkSQLServer(ctx, site)
+
+	case "websocket", "ws", "wss":
+		m.setDefaultTimeout(site, "websocket", DefWebSocketTimeoutMillis)
+		return m.checkWebSocket(ctx, site)
+
+	case "mqtt":
+		m.setDefaultTimeout(site, "mqtt", DefMQTTTimeoutMillis)
+		return m.checkMQTT(ctx, site)
+
+	case "memcached":
+		m.setDefaultTimeout(site, "memcached", DefMemcachedTimeoutMillis)
+		return m.checkMemcached(ctx, site)
+
+	case "nats":
+		m.setDefaultTimeout(site, "nats", DefNATSTimeoutMillis)
+		return m.checkNATS(ctx, site)
+
+	case "ssh":
+		m.setDefaultTimeout(site, "ssh", DefSSHTimeoutMillis)
+		return m.checkSSH(ctx, site)
+
+	case "postgres":
+		m.setDefaultTimeout(site, "postgres", DefPostgresTimeoutMillis)
+		return m.checkPostgres(ctx, site)
+
+	case "clickhouse":
+		m.setDefaultTimeout(site, "clickhouse", DefClickHouseTimeoutMillis)
+		return m.checkClickHouse(ctx, site)
+
+	case "ldap":
+		m.setDefaultTimeout(site, "ldap", DefLDAPTimeoutMillis)
+		return m.checkLDAP(ctx, site)
+
+	case "consul":
+		m.setDefaultTimeout(site, "consul", DefConsulTimeoutMillis)
+		return m.checkConsul(ctx, site)
+
+	case "etcd":
+		m.setDefaultTimeout(site, "etcd", DefEtcdTimeoutMillis)
+		return m.checkEtcd(ctx, site)
+
+	case "tcp":
+		m.setDefaultTimeout(site, "tcp", DefTCPTimeoutMillis)
+		return m.checkTCP(ctx, site)
+
+	case "amqp":
+		m.setDefaultTimeout(site, "amqp", DefAMQPTimeoutMillis)
+		return m.checkAMQP(ctx, site)
+
+	case "cassandra", "scylla":
+		m.setDefaultTimeout(site, "cassandra", DefCassandraTimeoutMillis)
+		return m.checkCassandra(ctx, site)
+
+	case "docker":
+		m.setDefaultTimeout(site, "docker", DefDockerTimeoutMillis)
+		return m.checkDocker(ctx, site)
+
+	case "kubernetes":
+		m.setDefaultTimeout(site, "kubernetes", DefKubernetesTimeoutMillis)
+		return m.checkKubernetes(ctx, site)
+
+	case "grpc":
+		m.setDefaultTimeout(site, "grpc", DefGRPCTimeoutMillis)
+		return m.checkGRPC(ctx, site)
+
+	case "oracle":
+		m.setDefaultTimeout(site, "oracle", DefOracleTimeoutMillis)
+		return m.checkOracle(ctx, site)
+
+	case "ntp":
+		m.setDefaultTimeout(site, "ntp", DefNTPTimeoutMillis)
+		return m.checkNTP(ctx, site)
 
 	default:
-		return fmt.Errorf("unhandled protocol: %s", site.Protocol)
+		return fmt.Errorf("%w: unhandled protocol: %s", ErrConfig, site.Protocol)
 	}
 }
 
-// resolveServer uses Go's native name resolver with the given DNS
-// server, to get addresses for the specified host.
-func (m *Monitor) resolveServer(host string) error {
-	_, err := m.resolver.LookupHost(context.Background(), host)
-	if err != nil {
+// invertResult interprets the outcome of a check of a site that is
+// expected to be unreachable.  Any failure to reach it, including a
+// timeout, is healthy.  Configuration errors remain errors.
+func invertResult(site *Site, err error) error {
+	switch {
+	case err == nil:
+		return fmt.Errorf("server is unexpectedly reachable")
+
+	case errors.Is(err, ErrConfig):
 		return err
+
+	default:
+		zLog.Info("invert",
+			zap.String("uri", site.Server),
+			zap.String("expected", err.Error()))
+		return nil
+	}
+}
+
+// setDefaultTimeout sets the timeout of the given site, if unspecified,
+// to the configured default for its protocol or, failing that, for its
+// protocol family.  The built-in default is used as the last resort.
+func (m *Monitor) setDefaultTimeout(site *Site, family string, def int64) {
+	if site.TimeoutMillis != 0 {
+		return
 	}
 
-	return nil
+	if v, ok := m.conf.Defaults[site.Protocol]; ok && v > 0 {
+		site.TimeoutMillis = v
+	} else if v, ok := m.conf.Defaults[family]; ok && v > 0 {
+		site.TimeoutMillis = v
+	} else {
+		site.TimeoutMillis = def
+	}
+}
+
+// builtinTimeoutMillis answers the built-in default timeout for the given
+// protocol family, as applied by `isServerUp`.
+func builtinTimeoutMillis(family string) int64 {
+	switch family {
+	case "http":
+		return DefHTTPTimeoutMillis
+	case "mysql":
+		return DefMySQLTimeoutMillis
+	case "sqlserver":
+		return DefSQLServerTimeoutMillis
+	case "websocket":
+		return DefWebSocketTimeoutMillis
+	case "mqtt":
+		return DefMQTTTimeoutMillis
+	case "memcached":
+		return DefMemcachedTimeoutMillis
+	case "nats":
+		return DefNATSTimeoutMillis
+	case "ssh":
+		return DefSSHTimeoutMillis
+	case "postgres":
+		return DefPostgresTimeoutMillis
+	case "clickhouse":
+		return DefClickHouseTimeoutMillis
+	case "ldap":
+		return DefLDAPTimeoutMillis
+	case "consul":
+		return DefConsulTimeoutMillis
+	case "etcd":
+		return DefEtcdTimeoutMillis
+	case "tcp":
+		return DefTCPTimeoutMillis
+	case "amqp":
+		return DefAMQPTimeoutMillis
+	case "cassandra":
+		return DefCassandraTimeoutMillis
+	case "docker":
+		return DefDockerTimeoutMillis
+	case "kubernetes":
+		return DefKubernetesTimeoutMillis
+	case "grpc":
+		return DefGRPCTimeoutMillis
+	case "oracle":
+		return DefOracleTimeoutMillis
+	case "ntp":
+		return DefNTPTimeoutMillis
+	}
+	return 0
+}
+
+// resolverTimeoutMillis answers the DNS resolution time limit for the
+// given site, falling back to the global limit.
+func (m *Monitor) resolverTimeoutMillis(site *Site) int64 {
+	if site.ResolverTimeoutMillis > 0 {
+		return int64(site.ResolverTimeoutMillis)
+	}
+	return int64(m.conf.ResolverTimeoutMillis)
+}
+
+// verifyCert answers whether the server's certificate should be
+// verified, given a site's explicit setting, if any.
+func (m *Monitor) verifyCert(v *bool) bool {
+	if v != nil {
+		return *v
+	}
+	return m.conf.DefaultVerifyCert
+}
+
+// resolveServer uses Go's native name resolver with the given DNS
+// server, to get addresses for the specified host.  A failed lookup is
+// retried up to `Config.DNSRetries` times, unless the host is reported
+// not to exist.
+func (m *Monitor) resolveServer(host string) ([]string, error) {
+	delay := time.Duration(m.conf.DNSRetryDelayMillis) * time.Millisecond
+	if delay <= 0 {
+		delay = DefDNSRetryDelayMillis * time.Millisecond
+	}
+
+	for attempt := 0; ; attempt++ {
+		addrs, err := m.resolver.LookupHost(context.Background(), host)
+		if err == nil {
+			if attempt > 0 {
+				zLog.Info("dns",
+					zap.String("uri", host),
+					zap.Int("attempts", attempt+1),
+					zap.String("outcome", "resolved"))
+			}
+			return addrs, nil
+		}
+
+		var dErr *net.DNSError
+		if attempt >= m.conf.DNSRetries || (errors.As(err, &dErr) && dErr.IsNotFound) {
+			if attempt > 0 {
+				err = fmt.Errorf("%w (after %d attempts)", err, attempt+1)
+			}
+			return nil, err
+		}
+		zLog.Warn("dns",
+			zap.String("uri", host),
+			zap.Int("attempt", attempt+1),
+			zap.Duration("retryIn", delay),
+			zap.String("error", err.Error()))
+		<-m.clock.After(delay)
+	}
+}
+
+// sameIPs answers if the given lists hold the same set of IP addresses,
+// irrespective of order, duplication and textual form.
+func sameIPs(a, b []string) bool {
+	set := func(ips []string) map[string]bool {
+		s := make(map[string]bool, len(ips))
+		for _, ip := range ips {
+			if p := net.ParseIP(ip); p != nil {
+				ip = p.String()
+			}
+			s[ip] = true
+		}
+		return s
+	}
+	return maps.Equal(set(a), set(b))
 }
 
 // sendAlert composes the alert message, and dispatches it using the
 // SMTP configuration given in the configuration.
 func (m *Monitor) sendAlert(recipients []string, server string, sErr error) error {
-	auth := LoginAuth(m.conf.Sender.Username, m.conf.Sender.Password)
-	fStr := "Subject: ALERT : Server not reachable : %s\r\n" +
+	fStr := "Subject: %s\r\n" +
 		"\r\n" +
 		"ERROR : Could not get heartbeat!\r\n" +
 		"\r\n" +
 		"Server : %s\r\n" +
 		"Reason : %s\r\n"
-	msg := fmt.Sprintf(fStr, server, server, sErr.Error())
+	subject := withEnvironment(m.conf.Environment, "ALERT : Server not reachable : "+server)
+	msg := fmt.Sprintf(fStr, subject, server, sErr.Error())
 
-	err := smtp.SendMail(m.mailServer, auth, m.conf.Sender.Username, recipients, []byte(msg))
+	err := m.sendOrSpool(smtpAuthLogin, recipients, []byte(msg))
 	if err != nil {
 		return err
 	}
@@ -100,108 +376,462 @@ func (m *Monitor) sendAlert(recipients []string, server string, sErr error) erro
 
 // sendGMailAlert composes the alert message, and dispatches it using the SMTP
 // configuration given in the configuration.
-func (m *Monitor) sendGmailAlert(recipients []string, svc, server string, sErr error) error {
-	auth := smtp.PlainAuth("", m.conf.Sender.Username, m.conf.Sender.Password, m.conf.Sender.Server)
+func (m *Monitor) sendGmailAlert(a *Alert) error {
+	message, err := m.composeGmailAlert(a)
+	if err != nil {
+		return err
+	}
+
+	// Send email
+	err = m.sendOrSpool(smtpAuthPlain, a.Recipients, message)
+
+	return err
+}
+
+// composeGmailAlert answers the message of the given alert, with
+// plain-text and HTML alternatives.
+func (m *Monitor) composeGmailAlert(a *Alert) ([]byte, error) {
+	recipients, server, sErr := a.Recipients, a.Site.Server, a.Err
+	at := a.At.Format(time.RFC3339)
 
 	// Construct email headers
 	headers := make(map[string]string)
 	headers["From"] = fmt.Sprintf("%s <%s>", m.conf.Sender.DisplayName, m.conf.Sender.Username)
+	if fo := a.Site.FromOverride; fo != nil && fo.Address != "" {
+		// Only the header changes; the envelope sender remains the
+		// authenticated account, so that the relay accepts the message.
+		headers["From"] = fmt.Sprintf("%s <%s>", fo.DisplayName, fo.Address)
+	}
 	headers["To"] = strings.Join(recipients, ",")
-	headers["Subject"] = "ALERT : Issue with '" + svc + "' : " + server
-	headers["MIME-Version"] = "1.0"
-	headers["Content-Type"] = "text/html; charset=UTF-8"
+	headers["Subject"] = a.subject()
 
-	// Build message
+	// Build the plain-text and HTML bodies.  Values are escaped in the
+	// latter, since errors may well contain markup.
+	heading, label := a.labels()
+	text := heading + "\r\n" +
+		"\r\n" +
+		"Server : " + server + "\r\n" +
+		label + " : " + sErr.Error() + "\r\n" +
+		"Detected at : " + at + "\r\n" +
+		"Monitor : " + a.Monitor + "\r\n"
+	body := `
+	<h3>` + html.EscapeString(heading) + `</h3>
+	<p>Server : ` + html.EscapeString(server) + `</p>
+	<p>` + label + ` : ` + html.EscapeString(sErr.Error()) + `</p>
+	<p>Detected at : ` + at + `</p>
+	<p>Monitor : ` + html.EscapeString(a.Monitor) + `</p>
+	`
+	if len(a.Timings) > 0 {
+		text += "\r\n"
+		body += "<table>\r\n<tr><th>Phase</th><th>ms</th></tr>\r\n"
+		for _, t := range a.Timings {
+			text += fmt.Sprintf("%s : %d ms\r\n", t.Phase, t.Millis)
+			body += fmt.Sprintf("<tr><td>%s</td><td>%d</td></tr>\r\n", html.EscapeString(t.Phase), t.Millis)
+		}
+		body += "</table>\r\n"
+	}
+
+	return buildAlternative(headers, text, body)
+}
+
+// buildAlternative composes a message with the given headers, whose body
+// offers the given plain-text and HTML alternatives.  Clients display the
+// last alternative that they support.  The parts are quoted-printable, so
+// that no line exceeds the limit of SMTP, however long an error is.
+func buildAlternative(headers map[string]string, text, body string) ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	headers["MIME-Version"] = "1.0"
+	headers["Content-Type"] = "multipart/alternative; boundary=" + mw.Boundary()
 	var message string
 	for key, value := range headers {
 		message += fmt.Sprintf("%s: %s\r\n", key, value)
 	}
-	message += "\r\n" + `
-	<h3>Issue observed in '` + svc + `'</h3>
-	<p>Server : ` + server + `</p>
-	<p>Issue : ` + sErr.Error() + `</p>
-	`
+	message += "\r\n"
 
-	// Send email
-	err := smtp.SendMail(
-		m.mailServer,
-		auth,
-		m.conf.Sender.Username,
-		recipients,
-		[]byte(message),
-	)
+	for _, part := range []struct{ typ, content string }{
+		{"text/plain; charset=UTF-8", text},
+		{"text/html; charset=UTF-8", body},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.typ},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qw := quotedprintable.NewWriter(w)
+		if _, err = io.WriteString(qw, part.content); err != nil {
+			return nil, err
+		}
+		if err = qw.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	return append([]byte(message), buf.Bytes()...), nil
+}
+
+// sendMail dispatches the given message, retrying with a jittered
+// exponential backoff in case of transient failures: network errors and
+// 4xx SMTP replies.  Permanent failures are not retried.
+func (m *Monitor) sendMail(auth smtp.Auth, recipients []string, msg []byte) error {
+	attempts := m.conf.Sender.Attempts
+	if attempts <= 0 {
+		attempts = DefSMTPAttempts
+	}
+
+	var err error
+	for i := 1; ; i++ {
+		err = smtp.SendMail(m.mailServer, auth, m.conf.Sender.Username, recipients, msg)
+		if err == nil || i >= attempts || !isTransientSMTPError(err) {
+			break
+		}
+
+		backoff := time.Duration(1<<(i-1)) * time.Second
+		backoff += rand.N(backoff / 2)
+		zLog.Warn("smtp",
+			zap.Int("attempt", i),
+			zap.Duration("backoff", backoff),
+			zap.String("error", err.Error()))
+		time.Sleep(backoff)
+	}
+
+	return err
+}
+
+// isTransientSMTPError answers whether the given error is worth retrying.
+func isTransientSMTPError(err error) bool {
+	var tpErr *textproto.Error
+	if errors.As(err, &tpErr) {
+		return tpErr.Code >= 400 && tpErr.Code < 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF)
+}
+
+// checkSite checks the given site once, as per its protocol, records
+// the outcome, and raises the alerts called for.  It answers the outcome
+// of the check.
+func (m *Monitor) checkSite(site Site) (err error) {
+	// Every path concludes the check exactly once, so that it is counted
+	// in the digest.
+	start := m.now()
+	concluded := false
+	conclude := func(svc string, err error, ms int64, dnsStatus string) error {
+		concluded = true
+		return m.concludeCheck(&site, svc, err, ms, dnsStatus)
+	}
+
+	defer func() {
+		// A panicking check must not take down the whole monitor;
+		// treat it as a failed check.
+		if r := recover(); r != nil {
+			zLog.Error("panic",
+				zap.String("uri", site.Server),
+				zap.String("protocol", site.Protocol),
+				zap.Any("panic", r),
+				zap.Stack("stack"))
+			err = fmt.Errorf("check panicked: %v", r)
+			if !concluded {
+				err = conclude(site.Protocol, err, m.since(start).Milliseconds(), "")
+			}
+		}
+	}()
+
+	// Perform an external DNS resolution, if asked for.  When DNS
+	// and service outcomes are combined, a slow resolution is
+	// reported together with the outcome of the service check.
+	dnsStatus := ""
+	var dnsErr error
+	if m.conf.ReportDNS || site.MinResolvedIPs > 0 || len(site.PinnedIPs) > 0 {
+		trb := m.now()
+		// Resolve the server, if it not an address.
+		if ip := net.ParseIP(site.Server); ip == nil {
+			addrs, err := m.resolveServer(site.Server)
+			if err != nil {
+				zLog.Error("dns",
+					zap.String("uri", site.Server),
+					zap.String("error", err.Error()))
+				if site.Invert {
+					// An unresolvable server is unreachable, as expected.
+					err = invertResult(&site, err)
+				}
+
+				return conclude("dns", err, m.since(trb).Milliseconds(), "")
+			}
+
+			dur := m.since(trb).Milliseconds()
+			zLog.Info("dns",
+				zap.String("uri", site.Server),
+				zap.Int64("ms", dur),
+				zap.Strings("addresses", addrs))
+			if least := site.MinResolvedIPs; least > 0 && len(addrs) < least {
+				zLog.Warn("dns",
+					zap.String("uri", site.Server),
+					zap.Int("resolved", len(addrs)),
+					zap.Int("minimum", least))
+				m.alert(&site, "dns", fmt.Errorf("resolved to %d addresses; at least %d expected", len(addrs), least))
+			}
+			if len(site.PinnedIPs) > 0 && !sameIPs(addrs, site.PinnedIPs) {
+				zLog.Warn("dns",
+					zap.String("uri", site.Server),
+					zap.Strings("resolved", addrs),
+					zap.Strings("pinned", site.PinnedIPs))
+				m.alert(&site, "dns", fmt.Errorf("resolved addresses %v differ from pinned addresses %v", addrs, site.PinnedIPs))
+			}
+			dnsStatus = fmt.Sprintf("OK (%d ms)", dur)
+			if dur >= m.resolverTimeoutMillis(&site) {
+				dnsErr = fmt.Errorf("DNS resolution time limit exceeded: %d ms", dur)
+				dnsStatus = dnsErr.Error()
+				if !m.conf.CombineDNSAlerts {
+					m.alert(&site, "dns", dnsErr)
+				}
+			}
+		}
+	}
+
+	// Check for response, as per the specified protocol.
+	ctx, span := m.startCheckSpan(&site)
+	notes := &checkNotes{}
+	ctx = context.WithValue(ctx, checkNotesKey{}, notes)
+	tb := m.now()
+	err = m.isServerUp(ctx, &site)
+	if notes.certErr != nil {
+		m.alert(&site, "certificate", notes.certErr)
+	}
+	// A slow resolution found by the check itself is combined likewise.
+	var dnsTimings []Timing
+	if dnsErr == nil && notes.dnsErr != nil {
+		dnsErr, dnsTimings = notes.dnsErr, notes.dnsTimings
+		dnsStatus = dnsErr.Error()
+	}
+	if site.Invert && !site.invertsPerPort() {
+		err = invertResult(&site, err)
+	}
+	endCheckSpan(span, err)
+	err = conclude(site.Protocol, err, m.since(tb).Milliseconds(), dnsStatus)
+	if err == nil && m.conf.CombineDNSAlerts && dnsErr != nil {
+		m.alert(&site, "dns", dnsErr, dnsTimings...)
+	}
 
 	return err
 }
 
+// concludeCheck records the outcome of a check of the given site, which
+// took the given time, in its state, the digest and the result webhook,
+// and raises the alert called for, or notifies the
+// recovery.  When DNS and service outcomes are combined, the given DNS
+// status, if any, is included in the alert.  It answers the outcome, as
+// alerted.
+func (m *Monitor) concludeCheck(site *Site, svc string, err error, ms int64, dnsStatus string) error {
+	m.recordDigest(site, err, ms)
+	m.postResult(site, err, ms)
+	downSince := m.recordResult(site, err)
+	if err != nil {
+		combine := m.conf.CombineDNSAlerts && dnsStatus != ""
+		// Each failing URL of a site is alerted on separately.
+		errs := []error{err}
+		var uErrs urlErrors
+		if errors.As(err, &uErrs) {
+			errs = uErrs
+		}
+		for _, e := range errs {
+			s := svc
+			if errors.Is(e, ErrConfig) {
+				s = "configuration"
+			}
+			if combine {
+				e = fmt.Errorf("DNS : %s ; service : %w", dnsStatus, e)
+			}
+			m.alert(site, s, e)
+		}
+		if combine {
+			err = fmt.Errorf("DNS : %s ; service : %w", dnsStatus, err)
+		}
+		return err
+	}
+
+	m.resolveIncidents(site)
+	if !downSince.IsZero() {
+		m.notifyRecovery(site, downSince, ms)
+	}
+	return nil
+}
+
+// checkNotesKey is the context key of the notes of a check.
+type checkNotesKey struct{}
+
+// checkNotes collects what a check finds besides its outcome, so that
+// each finding is alerted on once per check, however many probes and
+// retries the check takes.
+type checkNotes struct {
+	mu         sync.Mutex
+	certErr    error    // certificate pin mismatch
+	dnsErr     error    // slow resolution, when DNS alerts are combined
+	dnsTimings []Timing // of the probe that found `dnsErr`
+}
+
+// notesOf answers the notes of the check in the given context; nil
+// outside a check.
+func notesOf(ctx context.Context) *checkNotes {
+	n, _ := ctx.Value(checkNotesKey{}).(*checkNotes)
+	return n
+}
+
+// noteCert notes the given certificate pin mismatch; only the first one
+// is retained.
+func (n *checkNotes) noteCert(err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.certErr == nil {
+		n.certErr = err
+	}
+}
+
+// noteDNS notes the given slow resolution, found by a probe with the
+// given timings; only the first one is retained.
+func (n *checkNotes) noteDNS(err error, timings []Timing) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.dnsErr == nil {
+		n.dnsErr, n.dnsTimings = err, timings
+	}
+}
+
 // processSites is the main loop of the heartbeat checker.
 func (m *Monitor) processSites() {
-	l := len(m.conf.Sites)
+	tb := m.now()
+	// The site list may be swapped by a refresh meanwhile.
+	m.mu.Lock()
+	all := m.conf.Sites
+	m.mu.Unlock()
+	sites := make([]Site, 0, len(all))
+	for i := range all {
+		if m.isDue(&all[i]) {
+			sites = append(sites, all[i])
+		}
+	}
+	l := len(sites)
 	ch := make(chan bool)
 
-	for _, site := range m.conf.Sites {
-		go func(site Site, ch chan bool) {
-			defer func() {
-				ch <- true
-			}()
-
-			// Perform an external DNS resolution, if asked for.
-			if m.conf.ReportDNS {
-				trb := time.Now()
-				// Resolve the server, if it not an address.
-				if ip := net.ParseIP(site.Server); ip == nil {
-					err := m.resolveServer(site.Server)
-					if err != nil {
-						zLog.Error("dns",
-							zap.String("uri", site.Server),
-							zap.String("error", err.Error()))
-
-						dErr := m.sendGmailAlert(site.Recipients, "dns", site.Server, err)
-						if dErr != nil {
-							zLog.Error("alert",
-								zap.String("uri", site.Server),
-								zap.String("error", dErr.Error()))
-						}
-
-						return
-					}
-
-					dur := time.Since(trb).Milliseconds()
-					zLog.Info("dns",
+	// A site is checked after its dependency, and skipped while that is
+	// failing, so that the outage is attributed to the root dependency.
+	for _, level := range dependencyLevels(sites) {
+		for _, site := range level {
+			go func(site Site) {
+				if root := m.failingDependency(&site); root != "" {
+					zLog.Warn(site.Protocol,
 						zap.String("uri", site.Server),
-						zap.Int64("ms", dur))
-					if dur >= int64(m.conf.ResolverTimeoutMillis) {
-						sErr := fmt.Errorf("DNS resolution time limit exceeded: %d ms", dur)
-						dErr := m.sendGmailAlert(site.Recipients, "dns", site.Server, sErr)
-						if dErr != nil {
-							zLog.Error("alert",
-								zap.String("uri", site.Server),
-								zap.String("error", dErr.Error()))
-						}
-					}
+						zap.String("dependency", root),
+						zap.String("skipped", "dependency failing"))
+					ch <- true
+					return
 				}
-			}
+				unlock := m.acquireMutex(&site)
+				release := m.acquireSlot(&site)
+				m.checkSite(site)
+				release()
+				unlock()
+				ch <- true
+			}(site)
+		}
 
-			// Check for response, as per the specified protocol.
-			if err := m.isServerUp(&site); err != nil {
-				dErr := m.sendGmailAlert(site.Recipients, site.Protocol, site.Server, err)
-				if dErr != nil {
-					zLog.Error("alert",
-						zap.String("uri", site.Server),
-						zap.String("error", dErr.Error()))
-				}
-			}
-		}(site, ch)
+		for range level {
+			<-ch
+		}
 	}
 
-	for i := 0; i < l; i++ {
-		<-ch
+	// Record the duration of this sweep, and warn if the monitor cannot
+	// keep up with the heartbeat interval.
+	dur := m.since(tb).Milliseconds()
+	m.mu.Lock()
+	m.sweeps++
+	m.lastSweepMillis = dur
+	m.lastSweepAt = m.now()
+	m.mu.Unlock()
+
+	zLog.Info("sweep",
+		zap.Int("sites", l),
+		zap.Int64("ms", dur))
+	if interval := int64(m.tickSeconds()) * 1000; dur >= interval {
+		zLog.Warn("sweep",
+			zap.Int64("ms", dur),
+			zap.Int64("interval", interval),
+			zap.String("error", "sweep exceeded heartbeat interval"))
 	}
 }
 
+// sweep processes all the sites, unless the previous sweep is still in
+// progress, in which case this sweep is skipped.
+func (m *Monitor) sweep() {
+	if !m.sweeping.CompareAndSwap(false, true) {
+		zLog.Warn("sweep",
+			zap.String("error", "previous sweep still running; skipping"))
+		return
+	}
+	defer m.sweeping.Store(false)
+
+	m.refreshSites()
+	m.processSites()
+	m.sendDigest()
+	m.printProgress()
+}
+
+// printProgress reports the completion of a sweep on the standard
+// output: as a bare dot, by default, or as a one-line summary of the
+// status of all the sites.
+func (m *Monitor) printProgress() {
+	switch {
+	case m.quiet:
+		return
+
+	case m.summary:
+		up, failing := 0, 0
+		statuses := m.statuses()
+		for _, ss := range statuses {
+			if ss.Up {
+				up++
+			}
+			if ss.ConsecutiveFailures > 0 {
+				failing++
+			}
+		}
+		m.mu.Lock()
+		n := m.sweeps
+		m.mu.Unlock()
+		fmt.Printf("sweep #%d: %d/%d up, %d failing\n", n, up, len(statuses), failing)
+
+	default:
+		fmt.Print(".")
+	}
+}
+
+// Exit codes, distinguishing the causes of failure to start.
+const (
+	exitOK      = 0
+	exitConfig  = 2 // missing, corrupt or invalid configuration
+	exitLogging = 3
+	exitStartup = 4 // tracing could not be initialised
+	exitServer  = 5 // required status server could not be started
+)
+
 // main is the driver.
 func main() {
+	os.Exit(run())
+}
+
+// run runs the monitor until it is signalled to stop, and answers the
+// exit code.  Deferred cleanup completes before the process exits.
+func run() int {
 	fVersion := flag.Bool("v", false, "print version information")
+	fPrintConfig := flag.Bool("print-config", false, "print the effective configuration, and exit")
+	fQuiet := flag.Bool("quiet", false, "do not report sweeps on the standard output")
+	fSummary := flag.Bool("summary", false, "report each sweep as a one-line summary, instead of a dot")
 	flag.Parse()
 	if *fVersion {
 		progName := path.Base(os.Args[0])
@@ -213,78 +843,174 @@ func main() {
 		fmt.Printf("%[1]*[2]s : %[3]s\n", _l, "Built At", BuiltAt)
 		fmt.Printf("%[1]*[2]s : %[3]s\n", _l, "Built Using", GoVersion)
 		fmt.Println()
-		return
+		return exitOK
 	}
 
 	var err error
 
-	zCfg := []byte(`{
-		"level": "info",
-		"encoding": "json",
-		"outputPaths": ["log/` +
-		"hb.log." + time.Now().Format("2006-01-02_15-04-05") +
-		`"],
-		"errorOutputPaths": ["stderr"],
-		"encoderConfig": {
-		    "messageKey": "type",
-		    "levelKey": "level",
-		    "levelEncoder": "capital",
-		    "timeKey": "at",
-		    "timeEncoder": "iso8601"
-		}
-	}`)
-
-	// Initialise logger.
-	var cfg zap.Config
-	if err = json.Unmarshal(zCfg, &cfg); err != nil {
-		fmt.Printf("!! Unable to initialize logging : %s\n", err.Error())
-		return
-	}
-	zLog, err = cfg.Build()
-	if err != nil {
-		fmt.Printf("!! Unable to initialise logger : %s\n", err.Error())
-		return
-	}
-	defer zLog.Sync()
-
 	buf, err := os.ReadFile("config.json")
 	if err != nil {
 		fmt.Printf("!! Unable to read `config.json` : %s\n", err.Error())
-		return
+		return exitConfig
 	}
 
 	// Read the configuration.
 	m := &Monitor{
-		conf: &Config{},
+		conf:         &Config{},
+		clock:        realClock{},
+		quiet:        *fQuiet,
+		summary:      *fSummary,
+		sitesChanged: make(chan struct{}, 1),
 	}
+	m.startedAt = m.now()
 	err = json.Unmarshal(buf, m.conf)
 	if err != nil {
 		fmt.Printf("!! Corrupt configuration JSON : %s\n", err.Error())
-		return
+		return exitConfig
+	}
+	if m.conf.LogDir == "" {
+		m.conf.LogDir = DefLogDir
+	}
+	m.location = time.Local
+	if m.conf.Timezone != "" {
+		if m.location, err = time.LoadLocation(m.conf.Timezone); err != nil {
+			fmt.Printf("!! Invalid timezone : %s\n", err.Error())
+			return exitConfig
+		}
+	}
+	var logLoc *time.Location
+	if m.conf.LogTimezone {
+		logLoc = m.location
+	}
+
+	// Initialise logger.  Should the log directory be unusable, logging
+	// falls back to the standard error, if so configured.  Merely printing
+	// the configuration leaves no log behind.
+	if *fPrintConfig {
+		zLog = zap.NewNop()
+	} else if zLog, err = newLogger(m.conf.LogDir, logLoc); err != nil {
+		fmt.Printf("!! Unable to initialise logger : %s\n", err.Error())
+		m.alertStartupFailure(fmt.Errorf("unable to initialise logger: %w", err))
+		if m.conf.LogFallback != "stderr" {
+			return exitLogging
+		}
+		if zLog, err = buildLogger("stderr", logLoc); err != nil {
+			fmt.Printf("!! Unable to initialise fallback logger : %s\n", err.Error())
+			return exitLogging
+		}
+		fmt.Println("-- logging to the standard error")
 	}
+	defer zLog.Sync()
+	zLog.Info("version",
+		zap.String("version", ProgramVersion),
+		zap.String("gitCommit", GitCommit),
+		zap.String("builtAt", BuiltAt),
+		zap.String("goVersion", GoVersion))
+
+	// Apply defaults.
 	if m.conf.ResolverTimeoutMillis == 0 {
 		m.conf.ResolverTimeoutMillis = DefResolverTimeoutMillis
 	}
+	if err = m.conf.validate(); err != nil {
+		fmt.Printf("!! Invalid configuration :\n%s\n", err.Error())
+		m.alertStartupFailure(fmt.Errorf("invalid configuration: %w", err))
+		return exitConfig
+	}
+	if m.rootCAs, err = loadCAPool(m.conf.CACertFile, m.conf.CACertDir); err != nil {
+		fmt.Printf("!! Unable to load CA certificates : %s\n", err.Error())
+		return exitConfig
+	}
+	if err = m.loadCAPools(); err != nil {
+		fmt.Printf("!! Unable to load CA certificates : %s\n", err.Error())
+		return exitConfig
+	}
+	if err = m.compileSchemas(); err != nil {
+		fmt.Printf("!! Unable to compile response schemas : %s\n", err.Error())
+		return exitConfig
+	}
+	m.conf.applyGroups()
+	m.initGroupSlots()
+	effective := m.effectiveConfig()
+	if *fPrintConfig {
+		if err = effective.print(os.Stdout); err != nil {
+			fmt.Printf("!! Unable to print configuration : %s\n", err.Error())
+			return exitConfig
+		}
+		return exitOK
+	}
 	fmt.Println("-- starting with the following timeout specifications:")
 	fmt.Printf("\tresolver timeout: %d ms\n", m.conf.ResolverTimeoutMillis)
-	for _, s := range m.conf.Sites {
+	for _, s := range effective.Sites {
 		fmt.Printf("\ttimeout for '%s' on site '%s': %d ms\n", s.Protocol, s.Server, s.TimeoutMillis)
+		if s.ResolverTimeoutMillis > 0 {
+			fmt.Printf("\tresolver timeout for site '%s': %d ms\n", s.Server, s.ResolverTimeoutMillis)
+		}
+	}
+
+	// Identify this monitor instance in alerts.
+	if m.hostname, err = os.Hostname(); err != nil {
+		m.hostname = "unknown"
 	}
 
 	// Set the outgoing server and sender's name.
 	m.mailServer = fmt.Sprintf("%s:%d", m.conf.Sender.Server, m.conf.Sender.Port)
+	m.initNotifiers()
+	if err = m.checkNotifierRefs(); err != nil {
+		fmt.Printf("!! Invalid configuration :\n%s\n", err.Error())
+		m.alertStartupFailure(fmt.Errorf("invalid configuration: %w", err))
+		return exitConfig
+	}
+	if err = m.initTracing(); err != nil {
+		fmt.Printf("!! Unable to initialise tracing : %s\n", err.Error())
+		m.alertStartupFailure(fmt.Errorf("unable to initialise tracing: %w", err))
+		return exitStartup
+	}
+	defer m.stopTracing()
 
-	// Set the resolver dialer.
-	m.resolver = &net.Resolver{
-		PreferGo: true,
-		Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			d := net.Dialer{
-				Timeout: time.Millisecond * time.Duration(m.conf.ResolverTimeoutMillis),
-			}
-			return d.DialContext(ctx, "udp", m.conf.ResolverAddress+":53")
-		},
+	// Set the resolver dialer.  In the absence of a resolver address, the
+	// system resolver is used.
+	if m.conf.ResolverAddress == "" {
+		m.resolver = net.DefaultResolver
+		fmt.Println("-- using the system resolver")
+		zLog.Info("resolver", zap.String("mode", "system"))
+	} else {
+		m.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				d := net.Dialer{
+					Timeout: time.Millisecond * time.Duration(m.conf.ResolverTimeoutMillis),
+				}
+				return d.DialContext(ctx, "udp", net.JoinHostPort(m.conf.ResolverAddress, "53"))
+			},
+		}
+		fmt.Printf("-- using the resolver at %s\n", m.conf.ResolverAddress)
+		zLog.Info("resolver",
+			zap.String("mode", "custom"),
+			zap.String("address", m.conf.ResolverAddress))
 	}
 
+	// Start the embedded HTTP server, if asked for.
+	if err = m.startServer(); err != nil {
+		fmt.Printf("!! Unable to start the status server : %s\n", err.Error())
+		zLog.Error("server",
+			zap.Int("port", m.conf.StatusPort),
+			zap.String("error", err.Error()))
+		if m.conf.StatusServerRequired {
+			m.alertStartupFailure(fmt.Errorf("unable to start the status server: %w", err))
+			return exitServer
+		}
+	}
+	defer m.stopServer()
+
+	// Toggle alert suppression on SIGUSR1.
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGUSR1)
+		for range sig {
+			m.setPaused(!m.paused.Load(), "signal")
+		}
+	}()
+
 	// Main loop.
 	done := make(chan struct{})
 	go func(ch chan struct{}) {
@@ -296,21 +1022,49 @@ func main() {
 		close(ch)
 	}(done)
 
-	ticker := time.NewTicker(time.Duration(m.conf.HeartbeatSeconds) * time.Second)
-	defer ticker.Stop()
+	tick := m.tickSeconds()
+	ticker := m.clock.NewTicker(time.Duration(tick) * time.Second)
+	defer func() { ticker.Stop() }()
+	flushTicker := m.clock.NewTicker(alertHoursResolution)
+	defer flushTicker.Stop()
 
+	if m.conf.StartupGraceSeconds > 0 {
+		fmt.Printf("-- alerts suppressed for the first %d s\n", m.conf.StartupGraceSeconds)
+		zLog.Warn("startup grace period",
+			zap.Int("seconds", m.conf.StartupGraceSeconds))
+	}
 	fmt.Println("Starting heartbeat monitor ...")
-	m.processSites()
-	fmt.Print(".")
+	sdNotify("READY=1")
+	m.sweep()
 outer:
 	for {
 		select {
-		case <-ticker.C:
-			m.processSites()
-			fmt.Print(".")
+		case <-ticker.C():
+			// Sweeps run in the background, so that a slow sweep does not
+			// delay shutdown.
+			go m.sweep()
+
+		case <-m.sitesChanged:
+			// Fetched sites may be due at intervals that the current
+			// ticker does not divide.
+			if t := m.tickSeconds(); t != tick {
+				ticker.Stop()
+				tick = t
+				ticker = m.clock.NewTicker(time.Duration(tick) * time.Second)
+				zLog.Info("sites",
+					zap.Int("tickSeconds", tick))
+			}
+
+		case <-flushTicker.C():
+			// Deferred alerts are released as soon as alert hours open,
+			// independent of the sweeps.
+			m.flushDeferred()
 
 		case <-done:
 			break outer
 		}
 	}
+
+	sdNotify("STOPPING=1")
+	return exitOK
 }
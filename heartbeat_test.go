@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	zLog = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+// testEpoch is the time at which the clocks of test monitors start.
+var testEpoch = time.Date(2026, time.January, 5, 9, 0, 0, 0, time.UTC)
+
+// newTestMonitor answers a monitor with the given configuration, driven
+// by a fake clock, which is also answered.
+func newTestMonitor(conf *Config) (*Monitor, *fakeClock) {
+	clk := newFakeClock(testEpoch)
+	m := &Monitor{
+		conf:     conf,
+		clock:    clk,
+		hostname: "test",
+		tracer:   noop.NewTracerProvider().Tracer(tracerName),
+	}
+	m.startedAt = m.now()
+	return m, clk
+}
+
+// failingResolver answers a resolver that fails every lookup.
+func failingResolver() *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return nil, errors.New("no resolver in tests")
+		},
+	}
+}
+
+// unreachableAddr answers the address of a port on which nothing listens.
+func unreachableAddr(t *testing.T) (string, int) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().(*net.TCPAddr)
+	l.Close()
+	return addr.IP.String(), addr.Port
+}
+
+func TestInvertedChecks(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	open := l.Addr().(*net.TCPAddr).Port
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	host, closed := unreachableAddr(t)
+
+	tcp := func(port int) Site {
+		return Site{Protocol: "tcp", Server: host, TCPConfig: TCPConfig{Port: port}, TimeoutMillis: 500}
+	}
+	httpUp := *httpSite(t, srv)
+	httpDown := Site{Protocol: "http", Server: host, HTTPConfig: HTTPConfig{Port: closed}, TimeoutMillis: 500}
+	unresolvable := Site{Protocol: "tcp", Server: "absent.invalid", TCPConfig: TCPConfig{Port: 80}, TimeoutMillis: 500}
+
+	tests := []struct {
+		name   string
+		site   Site
+		invert bool
+		up     bool
+	}{
+		{"tcp open", tcp(open), false, true},
+		{"tcp closed", tcp(closed), false, false},
+		{"tcp open, inverted", tcp(open), true, false},
+		{"tcp closed, inverted", tcp(closed), true, true},
+		{"http up, inverted", httpUp, true, false},
+		{"http down, inverted", httpDown, true, true},
+		{"unresolvable", unresolvable, false, false},
+		{"unresolvable, inverted", unresolvable, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := newTestMonitor(&Config{HeartbeatSeconds: 60, ReportDNS: true})
+			m.resolver = failingResolver()
+			rn := &recordingNotifier{name: "rec"}
+			m.notifiers = []Notifier{rn}
+			tt.site.Invert = tt.invert
+
+			err := m.checkSite(tt.site)
+			if up := err == nil; up != tt.up {
+				t.Fatalf("up %v, expected %v; err: %v", up, tt.up, err)
+			}
+			if alerted := rn.count() > 0; alerted == tt.up {
+				t.Fatalf("%d alerts raised for a site that is up: %v", rn.count(), tt.up)
+			}
+		})
+	}
+}
+
+func TestUnknownProtocol(t *testing.T) {
+	site := Site{Protocol: "gopher", Server: "127.0.0.1"}
+
+	c := &Config{Sites: []Site{site}}
+	if err := c.validate(); !errors.Is(err, ErrConfig) {
+		t.Fatalf("validation answered %v, expected a configuration error", err)
+	}
+
+	m, _ := newTestMonitor(&Config{HeartbeatSeconds: 60})
+	rn := &recordingNotifier{name: "rec"}
+	m.notifiers = []Notifier{rn}
+	if err := m.checkSite(site); !errors.Is(err, ErrConfig) {
+		t.Fatalf("check answered %v, expected a configuration error", err)
+	}
+	if n := rn.count(); n != 1 {
+		t.Fatalf("%d alerts raised, expected 1", n)
+	}
+	if svc := rn.alerts[0].Service; svc != "configuration" {
+		t.Fatalf("alerted service %q, expected %q", svc, "configuration")
+	}
+}
+
+func TestPanickingCheck(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	m, _ := newTestMonitor(&Config{HeartbeatSeconds: 60, DigestIntervalHours: 24})
+	rn := &recordingNotifier{name: "rec"}
+	m.notifiers = []Notifier{rn}
+	site := *httpSite(t, srv)
+	site.HTTPConfig.KeepAlive = true
+	// The check panics on finding something else in place of the site's
+	// transport.
+	m.transports.Store(site.key(), "not a transport")
+
+	err := m.checkSite(site)
+	if err == nil || !strings.Contains(err.Error(), "check panicked") {
+		t.Fatalf("check answered %v, expected a panic", err)
+	}
+	if n := rn.count(); n != 1 {
+		t.Fatalf("%d alerts raised, expected 1", n)
+	}
+	m.withState(&site, func(st *siteState) {
+		if st.digestChecks != 1 || st.digestUp != 0 || st.digestAlerts != 1 {
+			t.Fatalf("%d checks, %d up, %d alerts; expected 1, 0, 1", st.digestChecks, st.digestUp, st.digestAlerts)
+		}
+	})
+}
+
+func TestAlertMessageParts(t *testing.T) {
+	m, _ := newTestMonitor(&Config{Sender: SenderConfig{Username: "hb@example.com"}})
+	sErr := errors.New(`unexpected body: <html>a & b</html>` + strings.Repeat("x", 2000))
+	a := &Alert{Site: &Site{Server: "h"}, Service: "http", Err: sErr, At: testEpoch, Monitor: "test", Recipients: []string{"ops@example.com"}}
+
+	msg, err := m.composeGmailAlert(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(string(msg), "\r\n") {
+		if len(line) > 998 {
+			t.Fatalf("line of %d characters", len(line))
+		}
+	}
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := make(map[string]string)
+	mr := multipart.NewReader(parsed.Body, params["boundary"])
+	for {
+		p, err := mr.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cte := p.Header.Get("Content-Transfer-Encoding"); cte != "quoted-printable" {
+			t.Fatalf("transfer encoding %q", cte)
+		}
+		buf, err := io.ReadAll(quotedprintable.NewReader(p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		typ, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		parts[typ] = string(buf)
+	}
+
+	if !strings.Contains(parts["text/plain"], sErr.Error()) {
+		t.Fatalf("error not verbatim in the text part:\n%s", parts["text/plain"])
+	}
+	escaped := html.EscapeString(sErr.Error())
+	if !strings.Contains(parts["text/html"], escaped) || strings.Contains(parts["text/html"], "<html>") {
+		t.Fatalf("error not escaped in the HTML part:\n%s", parts["text/html"])
+	}
+}
+
+func TestCheckContext(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	site := Site{Protocol: "tcp", Server: "127.0.0.1", TCPConfig: TCPConfig{Port: l.Addr().(*net.TCPAddr).Port}, TimeoutMillis: 500}
+
+	t.Run("phases traced", func(t *testing.T) {
+		m, _ := newTestMonitor(&Config{HeartbeatSeconds: 60})
+		rec := tracetest.NewSpanRecorder()
+		m.tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)).Tracer(tracerName)
+		if err := m.checkSite(site); err != nil {
+			t.Fatal(err)
+		}
+
+		spans := make(map[string]sdktrace.ReadOnlySpan)
+		for _, s := range rec.Ended() {
+			spans[s.Name()] = s
+		}
+		check, connect := spans["check tcp"], spans["connect"]
+		if check == nil || connect == nil {
+			t.Fatalf("spans %v; expected the check and its connect phase", spans)
+		}
+		if connect.Parent().SpanID() != check.SpanContext().SpanID() {
+			t.Fatal("connect phase not a child of the check")
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		m, _ := newTestMonitor(&Config{HeartbeatSeconds: 60})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := m.isServerUp(ctx, &site); err == nil || !strings.Contains(err.Error(), "canceled") {
+			t.Fatalf("expected a cancelled check; got %v", err)
+		}
+	})
+}
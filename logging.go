@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// newLogger answers a logger that writes to a fresh, time-stamped file in
+// the given directory, which is created if necessary.
+func newLogger(dir string, loc *time.Location) (*zap.Logger, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating log directory `%s` : %w", dir, err)
+	}
+	return buildLogger(filepath.Join(dir, "hb.log."+time.Now().Format("2006-01-02_15-04-05")), loc)
+}
+
+// buildLogger answers a JSON logger that writes to the given output path,
+// which may also be `stderr`.  Timestamps are in the given location, if
+// any.
+func buildLogger(out string, loc *time.Location) (*zap.Logger, error) {
+	logPath, _ := json.Marshal(out)
+	zCfg := []byte(`{
+		"level": "info",
+		"encoding": "json",
+		"outputPaths": [` + string(logPath) + `],
+		"errorOutputPaths": ["stderr"],
+		"encoderConfig": {
+		    "messageKey": "type",
+		    "levelKey": "level",
+		    "levelEncoder": "capital",
+		    "timeKey": "at",
+		    "timeEncoder": "iso8601"
+		}
+	}`)
+
+	var cfg zap.Config
+	if err := json.Unmarshal(zCfg, &cfg); err != nil {
+		return nil, err
+	}
+	if loc != nil {
+		cfg.EncoderConfig.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+			zapcore.ISO8601TimeEncoder(t.In(loc), enc)
+		}
+	}
+	return cfg.Build()
+}
+
+// alertStartupFailure sends a one-time email to the configured admin
+// recipients, if any, reporting that the monitor could not start
+// properly.  It uses SMTP directly, since logging may be unavailable.
+func (m *Monitor) alertStartupFailure(sErr error) {
+	if len(m.conf.AdminRecipients) == 0 || m.conf.Sender.Server == "" {
+		return
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	subject := withEnvironment(m.conf.Environment, "ALERT : Heartbeat monitor startup problem ["+host+"]")
+	msg := fmt.Sprintf("Subject: %s\r\n"+
+		"\r\n"+
+		"Monitor : %s\r\n"+
+		"Issue : %s\r\n", subject, host, sErr.Error())
+	addr := fmt.Sprintf("%s:%d", m.conf.Sender.Server, m.conf.Sender.Port)
+	auth := LoginAuth(m.conf.Sender.Username, m.conf.Sender.Password)
+	if err = smtp.SendMail(addr, auth, m.conf.Sender.Username, m.conf.AdminRecipients, []byte(msg)); err != nil {
+		fmt.Printf("!! Unable to send startup alert : %s\n", err.Error())
+	}
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+// Alert describes an issue observed with a site.
+type Alert struct {
+	Site       *Site
+	Service    string
+	Err        error
+	At         time.Time // when the issue was detected
+	Monitor    string    // hostname of the detecting monitor
+	Timings    []Timing  // phase timings, if any
+	Recipients []string  // the site's, and those added by routing rules
+	Recovery   bool      // the site has recovered; `Err` describes the outage
+	Env        string    // `Config.Environment`
+}
+
+// kind answers the label with which the alert is introduced.
+func (a *Alert) kind() string {
+	if a.Recovery {
+		return "RECOVERED"
+	}
+	return "ALERT"
+}
+
+// subject answers the one-line summary of the alert.
+func (a *Alert) subject() string {
+	switch {
+	case a.Recovery:
+		return withEnvironment(a.Env, "RECOVERED : '"+a.Service+"' : "+a.Site.Server+" ["+a.Monitor+"]")
+	case errors.Is(a.Err, ErrConfig):
+		return withEnvironment(a.Env, "ALERT : Configuration error : "+a.Site.Server+" ["+a.Monitor+"]")
+	default:
+		return withEnvironment(a.Env, "ALERT : Issue with '"+a.Service+"' : "+a.Site.Server+" ["+a.Monitor+"]")
+	}
+}
+
+// withEnvironment prefixes the given subject with the given environment
+// label, if any, e.g. `[PROD] ALERT : ...`.
+func withEnvironment(env, subject string) string {
+	if env == "" {
+		return subject
+	}
+	return "[" + env + "] " + subject
+}
+
+// labels answers the heading of the alert's body, and the label of its
+// error.
+func (a *Alert) labels() (string, string) {
+	if a.Recovery {
+		return "Recovery observed in '" + a.Service + "'", "Outage"
+	}
+	return "Issue observed in '" + a.Service + "'", "Issue"
+}
+
+// Timing is the duration of a phase of a check.
+type Timing struct {
+	Phase  string
+	Millis int64
+}
+
+// truncate answers the given text cut to at most the given number of
+// bytes, backing off to a rune boundary, so that no character is split.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// Notifier dispatches alerts through a particular channel.
+type Notifier interface {
+	Name() string
+	Notify(a *Alert) error
+}
+
+// incidentResolver is implemented by notifiers that open incidents, which
+// are to be resolved once the site recovers.
+type incidentResolver interface {
+	Resolve(site *Site, healthySince, now time.Time) error
+}
+
+// emailNotifier dispatches alerts as email, using the sender
+// configuration.
+type emailNotifier struct {
+	m *Monitor
+}
+
+func (n *emailNotifier) Name() string {
+	return "email"
+}
+
+func (n *emailNotifier) Notify(a *Alert) error {
+	return n.m.sendGmailAlert(a)
+}
+
+// unavailableNotifier stands in for a notifier that could not be
+// constructed, so that the others continue to deliver.  Every alert
+// offered to it fails with the construction error.
+type unavailableNotifier struct {
+	name string
+	err  error
+}
+
+func (n *unavailableNotifier) Name() string {
+	return n.name
+}
+
+func (n *unavailableNotifier) Notify(a *Alert) error {
+	return fmt.Errorf("notifier unavailable: %w", n.err)
+}
+
+// initNotifiers constructs the notifiers specified in the configuration.
+// Email is always available.  A notifier that cannot be constructed is
+// replaced by an unavailable one, and reported to the admins.
+func (m *Monitor) initNotifiers() {
+	m.notifiers = []Notifier{&emailNotifier{m: m}}
+
+	for _, nc := range m.conf.Notifiers {
+		var n Notifier
+		var err error
+
+		switch nc.Type {
+		case "sns":
+			n, err = newSNSNotifier(nc.Name, &nc.SNS)
+
+		case "twilio":
+			n, err = newTwilioNotifier(nc.Name, &nc.Twilio)
+
+		case "pagerduty":
+			n, err = newPagerDutyNotifier(nc.Name, &nc.PagerDuty)
+
+		default:
+			err = fmt.Errorf("unhandled notifier type: %s", nc.Type)
+		}
+		if err != nil {
+			err = fmt.Errorf("notifier '%s': %w", nc.Name, err)
+			fmt.Printf("!! Unable to initialise notifier : %s\n", err.Error())
+			zLog.Error("notifier",
+				zap.String("notifier", nc.Name),
+				zap.String("error", err.Error()))
+			m.alertStartupFailure(err)
+			n = &unavailableNotifier{name: nc.Name, err: err}
+		}
+		m.notifiers = append(m.notifiers, n)
+	}
+}
+
+// checkNotifierRefs ensures that the sites and routing rules reference
+// only known notifiers.
+func (m *Monitor) checkNotifierRefs() error {
+	known := make(map[string]bool, len(m.notifiers))
+	for _, n := range m.notifiers {
+		known[n.Name()] = true
+	}
+	for _, site := range m.conf.Sites {
+		for _, name := range site.Notifiers {
+			if !known[name] {
+				return fmt.Errorf("%w: site '%s' : unknown notifier '%s'", ErrConfig, site.Server, name)
+			}
+		}
+	}
+	for i, rule := range m.conf.Routes {
+		for _, name := range rule.Notifiers {
+			if !known[name] {
+				return fmt.Errorf("%w: route #%d : unknown notifier '%s'", ErrConfig, i+1, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// route answers the names of the notifiers to use for alerts about the
+// given site, and the recipients of those alerts.  Routing rules whose
+// tags match the site's add to its own selection.  A nil set of names
+// means all notifiers.
+func (m *Monitor) route(site *Site) (map[string]bool, []string) {
+	var names map[string]bool
+	if len(site.Notifiers) > 0 {
+		names = make(map[string]bool)
+		for _, name := range site.Notifiers {
+			names[name] = true
+		}
+	}
+	recipients := append([]string(nil), site.Recipients...)
+	seen := make(map[string]bool)
+	for _, r := range recipients {
+		seen[r] = true
+	}
+
+	for _, rule := range m.conf.Routes {
+		if !site.hasAnyTag(rule.Tags) {
+			continue
+		}
+		if names != nil {
+			for _, name := range rule.Notifiers {
+				names[name] = true
+			}
+		}
+		for _, r := range rule.Recipients {
+			if !seen[r] {
+				seen[r] = true
+				recipients = append(recipients, r)
+			}
+		}
+	}
+
+	return names, recipients
+}
+
+// hasAnyTag answers whether the site carries any of the given tags.
+func (s *Site) hasAnyTag(tags []string) bool {
+	for _, t := range tags {
+		for _, st := range s.Tags {
+			if t == st {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// alert dispatches an alert about the given site through all the
+// notifiers.  Delivery failures are logged.  Phase timings, if given, are
+// included in the alert to aid triage.
+func (m *Monitor) alert(site *Site, svc string, sErr error, timings ...Timing) {
+	if m.suppressed(site, svc, sErr) {
+		return
+	}
+	m.countDigestAlert(site)
+
+	a := &Alert{
+		Site:    site,
+		Service: svc,
+		Err:     sErr,
+		At:      m.localTime(m.now()),
+		Monitor: m.hostname,
+		Timings: timings,
+		Env:     m.conf.Environment,
+	}
+	if site.AlertHours != nil && !site.AlertHours.contains(a.At) {
+		m.deferAlert(a)
+		return
+	}
+	zLog.Info("alert",
+		zap.String("uri", site.Server),
+		zap.String("service", svc),
+		zap.String("error", sErr.Error()),
+		zap.String("at", a.At.Format(time.RFC3339)),
+		zap.String("monitor", a.Monitor))
+	m.dispatch(a)
+}
+
+// suppressed answers whether alerts about the given site are currently
+// suppressed: while alerts are paused, during the startup grace period,
+// or while the site's failure is acknowledged.  A suppressed alert is
+// logged.
+func (m *Monitor) suppressed(site *Site, svc string, sErr error) bool {
+	var reason string
+	switch {
+	case m.paused.Load():
+		reason = "alerts paused"
+	case m.inStartupGrace():
+		reason = "startup grace period"
+	case m.isAcknowledged(site):
+		reason = "acknowledged"
+	default:
+		return false
+	}
+
+	zLog.Warn("alert",
+		zap.String("uri", site.Server),
+		zap.String("service", svc),
+		zap.String("error", sErr.Error()),
+		zap.String("suppressed", reason))
+	return true
+}
+
+// dispatch sends the given alert through each of the notifiers selected
+// for its site.
+func (m *Monitor) dispatch(a *Alert) {
+	names, recipients := m.route(a.Site)
+	a.Recipients = recipients
+	for _, n := range m.notifiers {
+		if names != nil && !names[n.Name()] {
+			continue
+		}
+		if err := notify(n, a); err != nil {
+			zLog.Error("alert",
+				zap.String("notifier", n.Name()),
+				zap.String("uri", a.Site.Server),
+				zap.String("error", err.Error()))
+		}
+	}
+}
+
+// notify offers the given alert to the given notifier, converting a
+// panic into an error, so that the remaining notifiers are not affected.
+func notify(n Notifier, a *Alert) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return n.Notify(a)
+}
+
+// resolveIncidents offers the recovery of the given site to the notifiers
+// that open incidents.
+func (m *Monitor) resolveIncidents(site *Site) {
+	var since time.Time
+	m.withState(site, func(st *siteState) {
+		since = st.healthySince
+	})
+	if since.IsZero() {
+		return
+	}
+
+	now := m.now()
+	for _, n := range m.notifiers {
+		r, ok := n.(incidentResolver)
+		if !ok {
+			continue
+		}
+		if err := r.Resolve(site, since, now); err != nil {
+			zLog.Error("alert",
+				zap.String("notifier", n.Name()),
+				zap.String("uri", site.Server),
+				zap.String("error", err.Error()))
+		}
+	}
+}
+
+// setPaused pauses or resumes the dispatch of all alerts.  Checks
+// continue to run and be logged while alerts are paused.
+func (m *Monitor) setPaused(paused bool, source string) {
+	m.paused.Store(paused)
+	msg := "alerts resumed"
+	if paused {
+		msg = "alerts paused"
+	}
+	zLog.Warn(msg, zap.String("source", source))
+	fmt.Printf("-- %s (%s)\n", msg, source)
+}
+
+// inStartupGrace answers whether the monitor is still within the grace
+// period following its start, during which alerts are suppressed.
+func (m *Monitor) inStartupGrace() bool {
+	grace := time.Duration(m.conf.StartupGraceSeconds) * time.Second
+	return m.since(m.startedAt) < grace
+}
+
+// notifyRecovery notifies the recovery of the given site from the outage
+// that began at the given time, together with the latency of the check
+// that succeeded.  Notifiers that open incidents resolve them instead.
+// Alerts still deferred for the site are discarded.
+func (m *Monitor) notifyRecovery(site *Site, downSince time.Time, ms int64) {
+	m.dropDeferred(site)
+	now := m.now()
+	a := &Alert{
+		Site:     site,
+		Service:  site.Protocol,
+		Err:      fmt.Errorf("down for %s, since %s; current latency : %d ms", now.Sub(downSince).Round(time.Second), m.localTime(downSince).Format(time.RFC3339), ms),
+		At:       m.localTime(now),
+		Monitor:  m.hostname,
+		Recovery: true,
+		Env:      m.conf.Environment,
+	}
+	zLog.Info("recovery",
+		zap.String("uri", site.Server),
+		zap.Time("downSince", downSince),
+		zap.Duration("outage", now.Sub(downSince)),
+		zap.Int64("ms", ms))
+	if m.paused.Load() || m.inStartupGrace() {
+		return
+	}
+	if site.AlertHours != nil && !site.AlertHours.contains(a.At) {
+		m.deferAlert(a)
+		return
+	}
+	m.dispatch(a)
+}
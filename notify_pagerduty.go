@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// pagerDutyEventsURL is the endpoint of PagerDuty's Events API v2.
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// pagerDutyNotifier triggers PagerDuty incidents, one per site, and
+// resolves them once the site has stayed healthy long enough.
+type pagerDutyNotifier struct {
+	name          string
+	routingKey    string
+	resolveAfter  time.Duration
+	client        *http.Client
+	mu            sync.Mutex
+	openIncidents map[string]time.Time // site key -> last triggered at
+}
+
+// newPagerDutyNotifier answers a notifier that sends events with the
+// configured routing key.
+func newPagerDutyNotifier(name string, conf *PagerDutyConfig) (*pagerDutyNotifier, error) {
+	if conf.RoutingKey == "" {
+		return nil, fmt.Errorf("PagerDuty routing key not specified")
+	}
+
+	return &pagerDutyNotifier{
+		name:          name,
+		routingKey:    conf.RoutingKey,
+		resolveAfter:  time.Duration(conf.ResolveAfterHealthySeconds) * time.Second,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		openIncidents: make(map[string]time.Time),
+	}, nil
+}
+
+func (n *pagerDutyNotifier) Name() string {
+	return n.name
+}
+
+func (n *pagerDutyNotifier) Notify(a *Alert) error {
+	if a.Recovery {
+		return nil // see Resolve
+	}
+	key := a.Site.key()
+	err := n.send(map[string]interface{}{
+		"routing_key":  n.routingKey,
+		"event_action": "trigger",
+		"dedup_key":    key,
+		"payload": map[string]interface{}{
+			"summary":   fmt.Sprintf("%s : %s : %s", a.Site.Server, a.Service, a.Err.Error()),
+			"source":    a.Site.Server,
+			"severity":  "critical",
+			"timestamp": a.At.Format(time.RFC3339),
+			"component": a.Service,
+			"custom_details": map[string]string{
+				"monitor": a.Monitor,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	n.openIncidents[key] = a.At
+	n.mu.Unlock()
+	return nil
+}
+
+// Resolve resolves the open incident of the given site, if any, provided
+// that the site has been healthy since the given instant for at least the
+// configured period, counted from the incident's latest trigger.
+func (n *pagerDutyNotifier) Resolve(site *Site, healthySince, now time.Time) error {
+	key := site.key()
+	n.mu.Lock()
+	triggeredAt, ok := n.openIncidents[key]
+	n.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	if now.Sub(latest(healthySince, triggeredAt)) < n.resolveAfter {
+		return nil
+	}
+
+	err := n.send(map[string]interface{}{
+		"routing_key":  n.routingKey,
+		"event_action": "resolve",
+		"dedup_key":    key,
+	})
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	if n.openIncidents[key].Equal(triggeredAt) {
+		delete(n.openIncidents, key)
+	}
+	n.mu.Unlock()
+	zLog.Info("alert",
+		zap.String("notifier", n.name),
+		zap.String("uri", site.Server),
+		zap.String("resolved", "healthy since "+healthySince.Format(time.RFC3339)))
+	return nil
+}
+
+// send posts the given event to PagerDuty.
+func (n *pagerDutyNotifier) send(event map[string]interface{}) error {
+	buf, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	resp, err := n.client.Post(pagerDutyEventsURL, "application/json", bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status: %d, response: %s", resp.StatusCode, body)
+	}
+	return nil
+}
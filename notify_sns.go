@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+// snsMaxSubject is the maximum length of an SNS message subject.
+const snsMaxSubject = 100
+
+// snsNotifier publishes alerts to an AWS SNS topic.  Credentials are
+// obtained from the default chain.
+type snsNotifier struct {
+	name     string
+	topicARN string
+	client   *sns.Client
+}
+
+// newSNSNotifier answers a notifier that publishes to the configured
+// topic.
+func newSNSNotifier(name string, conf *SNSConfig) (*snsNotifier, error) {
+	if conf.TopicARN == "" {
+		return nil, fmt.Errorf("SNS topic ARN not specified")
+	}
+
+	ctx, cFunc := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cFunc()
+	awsConf, err := config.LoadDefaultConfig(ctx, config.WithRegion(conf.Region))
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS configuration: %w", err)
+	}
+
+	return &snsNotifier{
+		name:     name,
+		topicARN: conf.TopicARN,
+		client:   sns.NewFromConfig(awsConf),
+	}, nil
+}
+
+func (n *snsNotifier) Name() string {
+	return n.name
+}
+
+func (n *snsNotifier) Notify(a *Alert) error {
+	subject := a.subject()
+	subject = truncate(subject, snsMaxSubject)
+	heading, label := a.labels()
+	msg := fmt.Sprintf("%s\n\nServer : %s\n%s : %s\nDetected at : %s\nMonitor : %s\n",
+		heading, a.Site.Server, label, a.Err.Error(), a.At.Format(time.RFC3339), a.Monitor)
+	for _, t := range a.Timings {
+		msg += fmt.Sprintf("%s : %d ms\n", t.Phase, t.Millis)
+	}
+
+	ctx, cFunc := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cFunc()
+	_, err := n.client.Publish(ctx, &sns.PublishInput{
+		TopicArn: aws.String(n.topicARN),
+		Subject:  aws.String(subject),
+		Message:  aws.String(msg),
+	})
+	return err
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"exactly", 7, "exactly"},
+		{"ascii text", 5, "ascii"},
+		{"héllo", 2, "h"},
+		{"héllo", 3, "hé"},
+		{"服务器宕机", 7, "服务"},
+		{"服务器宕机", 2, ""},
+	}
+	for _, tt := range tests {
+		got := truncate(tt.s, tt.n)
+		if got != tt.want || !utf8.ValidString(got) {
+			t.Errorf("truncate(%q, %d) = %q, expected %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
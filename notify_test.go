@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// panickingNotifier panics on every alert.
+type panickingNotifier struct{}
+
+func (panickingNotifier) Name() string {
+	return "panicking"
+}
+
+func (panickingNotifier) Notify(a *Alert) error {
+	panic("notifier bug")
+}
+
+func TestFailingNotifierDoesNotBlockOthers(t *testing.T) {
+	m, _ := newTestMonitor(&Config{
+		HeartbeatSeconds: 60,
+		Notifiers:        []NotifierConfig{{Name: "broken", Type: "unknown"}},
+	})
+	m.initNotifiers()
+	if n := len(m.notifiers); n != 2 {
+		t.Fatalf("%d notifiers, expected email and the unavailable one", n)
+	}
+	broken, ok := m.notifiers[1].(*unavailableNotifier)
+	if !ok || broken.Name() != "broken" {
+		t.Fatalf("notifier %#v, expected the unavailable one", m.notifiers[1])
+	}
+
+	good := &recordingNotifier{name: "good"}
+	m.notifiers = []Notifier{broken, panickingNotifier{}, good}
+	site := &Site{Name: "a", Server: "h"}
+	m.alert(site, "tcp", errors.New("down"))
+	if n := good.count(); n != 1 {
+		t.Fatalf("%d alerts delivered by the working notifier, expected 1", n)
+	}
+}
+
+func TestUnknownNotifierReference(t *testing.T) {
+	m, _ := newTestMonitor(&Config{
+		Sites: []Site{{Name: "a", Server: "h", Notifiers: []string{"absent"}}},
+	})
+	m.initNotifiers()
+	if err := m.checkNotifierRefs(); !errors.Is(err, ErrConfig) {
+		t.Fatalf("expected a configuration error; got %v", err)
+	}
+}
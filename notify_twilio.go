@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// twilioMaxBody caps the length of an SMS alert, so that it spans only a
+// couple of segments.
+const twilioMaxBody = 320
+
+// twilioNotifier sends alerts as SMS messages through the Twilio REST
+// API.
+type twilioNotifier struct {
+	name   string
+	conf   *TwilioConfig
+	client *http.Client
+}
+
+// newTwilioNotifier answers a notifier that sends SMS messages from the
+// configured number.
+func newTwilioNotifier(name string, conf *TwilioConfig) (*twilioNotifier, error) {
+	if conf.AccountSID == "" || conf.AuthToken == "" {
+		return nil, fmt.Errorf("Twilio account SID and auth token must be specified")
+	}
+	if conf.From == "" {
+		return nil, fmt.Errorf("Twilio from-number not specified")
+	}
+
+	return &twilioNotifier{
+		name:   name,
+		conf:   conf,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (n *twilioNotifier) Name() string {
+	return n.name
+}
+
+func (n *twilioNotifier) Notify(a *Alert) error {
+	to := a.Site.SMSNumbers
+	if len(to) == 0 {
+		to = n.conf.To
+	}
+	if len(to) == 0 {
+		return fmt.Errorf("no SMS numbers for site '%s'", a.Site.Server)
+	}
+
+	msg := withEnvironment(a.Env, fmt.Sprintf("%s %s %s: %s", a.kind(), a.Site.Server, a.Service, a.Err.Error()))
+	if len(msg) > twilioMaxBody {
+		msg = truncate(msg, twilioMaxBody-3) + "..."
+	}
+
+	var errs []error
+	for _, num := range to {
+		if err := n.send(num, msg); err != nil {
+			zLog.Error("sms",
+				zap.String("notifier", n.name),
+				zap.String("to", num),
+				zap.String("uri", a.Site.Server),
+				zap.String("error", err.Error()))
+			errs = append(errs, fmt.Errorf("%s : %w", num, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// send sends the given message to the given number.
+func (n *twilioNotifier) send(to, msg string) error {
+	form := url.Values{}
+	form.Set("To", to)
+	form.Set("From", n.conf.From)
+	form.Set("Body", msg)
+
+	u := "https://api.twilio.com/2010-04-01/Accounts/" + url.PathEscape(n.conf.AccountSID) + "/Messages.json"
+	req, err := http.NewRequest(http.MethodPost, u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(n.conf.AccountSID, n.conf.AuthToken)
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		buf, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status: %d, response: %s", resp.StatusCode, buf)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// roundTripFunc adapts a function to `http.RoundTripper`.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestTwilioTruncatesOnRunes(t *testing.T) {
+	n, err := newTwilioNotifier("sms", &TwilioConfig{AccountSID: "AC1", AuthToken: "t", From: "+1000", To: []string{"+2000"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body string
+	n.client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		buf, _ := io.ReadAll(r.Body)
+		form, _ := url.ParseQuery(string(buf))
+		body = form.Get("Body")
+		return &http.Response{StatusCode: http.StatusCreated, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+	})
+
+	a := &Alert{
+		Site:    &Site{Server: "h"},
+		Service: "tcp",
+		Err:     errors.New(strings.Repeat("连接被拒绝", 40)),
+		At:      time.Now(),
+	}
+	if err := n.Notify(a); err != nil {
+		t.Fatal(err)
+	}
+	if !utf8.ValidString(body) {
+		t.Fatalf("invalid UTF-8 sent: %q", body)
+	}
+	if len(body) > twilioMaxBody || !strings.HasSuffix(body, "...") {
+		t.Fatalf("body of %d bytes, expected a truncated one of at most %d", len(body), twilioMaxBody)
+	}
+}
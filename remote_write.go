@@ -0,0 +1,47 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// remoteWriteMetric names the sample sent by a remote-write probe.
+const remoteWriteMetric = "heartbeat_remote_write_probe"
+
+// remoteWritePayload answers a minimal, valid Prometheus remote-write
+// request: a single sample, encoded as a `prometheus.WriteRequest`
+// protobuf message, and compressed with snappy's block format.
+func remoteWritePayload(monitor string, at time.Time) []byte {
+	label := func(name, value string) []byte {
+		var b []byte
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendString(b, name)
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendString(b, value)
+		return b
+	}
+
+	var sample []byte
+	sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+	sample = protowire.AppendFixed64(sample, math.Float64bits(1))
+	sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+	sample = protowire.AppendVarint(sample, uint64(at.UnixMilli()))
+
+	// Labels must be sorted by name.
+	var series []byte
+	series = protowire.AppendTag(series, 1, protowire.BytesType)
+	series = protowire.AppendBytes(series, label("__name__", remoteWriteMetric))
+	series = protowire.AppendTag(series, 1, protowire.BytesType)
+	series = protowire.AppendBytes(series, label("monitor", monitor))
+	series = protowire.AppendTag(series, 2, protowire.BytesType)
+	series = protowire.AppendBytes(series, sample)
+
+	var req []byte
+	req = protowire.AppendTag(req, 1, protowire.BytesType)
+	req = protowire.AppendBytes(req, series)
+
+	return snappy.Encode(nil, req)
+}
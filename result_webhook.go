@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefResultWebhookTimeoutMillis is used in case of no specification in
+// config.
+const DefResultWebhookTimeoutMillis = 5000
+
+// ResultSignatureHeader carries the signature of a result posted to the
+// result webhook.
+const ResultSignatureHeader = "X-Heartbeat-Signature"
+
+// ResultEvent is the outcome of a check, as posted to the result webhook.
+type ResultEvent struct {
+	Site     string    `json:"site"`
+	Protocol string    `json:"protocol"`
+	Server   string    `json:"server"`
+	Up       bool      `json:"up"`
+	Error    string    `json:"error,omitempty"`
+	Millis   int64     `json:"millis"`
+	At       time.Time `json:"at"` // when the check completed
+	Monitor  string    `json:"monitor"`
+}
+
+// signResult answers the signature of the given request body: the
+// lower-case hex encoding of its HMAC-SHA256, keyed with the given
+// secret, prefixed with `sha256=`.
+//
+// A receiver verifies a result by computing the same over the raw bytes
+// of the request body, exactly as received, and comparing it with the
+// value of the `X-Heartbeat-Signature` header in constant time.  The
+// `at` field of the body may be used to reject stale, replayed results.
+func signResult(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// postResult posts the outcome of a check of the given site to the
+// result webhook, if one is configured.  Every result is posted, in the
+// background, so that checks are not delayed by the receiver.
+func (m *Monitor) postResult(site *Site, err error, ms int64) {
+	wh := m.conf.ResultWebhook
+	if wh.URL == "" {
+		return
+	}
+
+	ev := ResultEvent{
+		Site:     site.key(),
+		Protocol: site.Protocol,
+		Server:   site.Server,
+		Up:       err == nil,
+		Millis:   ms,
+		At:       m.now(),
+		Monitor:  m.hostname,
+	}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	body, mErr := json.Marshal(ev)
+	if mErr != nil {
+		return
+	}
+
+	go func() {
+		if err := m.sendResult(body); err != nil {
+			zLog.Error("webhook",
+				zap.String("uri", site.Server),
+				zap.String("error", err.Error()))
+		}
+	}()
+}
+
+// sendResult posts the given, encoded result to the result webhook,
+// signed with the shared secret.
+func (m *Monitor) sendResult(body []byte) error {
+	wh := m.conf.ResultWebhook
+	timeout := time.Duration(wh.TimeoutMillis) * time.Millisecond
+	if timeout <= 0 {
+		timeout = DefResultWebhookTimeoutMillis * time.Millisecond
+	}
+
+	req, err := http.NewRequest(http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(ResultSignatureHeader, signResult(wh.Secret, body))
+
+	cl := &http.Client{Timeout: timeout}
+	resp, err := cl.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// tickSeconds answers the period of the monitor's ticker: the greatest
+// common divisor of the heartbeat interval, and of all the per-site
+// intervals.  Each site is checked on the ticks at which it is due.
+func (c *Config) tickSeconds() int {
+	gcd := func(a, b int) int {
+		for b != 0 {
+			a, b = b, a%b
+		}
+		return a
+	}
+
+	tick := c.HeartbeatSeconds
+	for i := range c.Sites {
+		if s := c.Sites[i].IntervalSeconds; s > 0 {
+			tick = gcd(tick, s)
+		}
+		if s := c.Sites[i].FastIntervalSeconds; s > 0 {
+			tick = gcd(tick, s)
+		}
+	}
+	return tick
+}
+
+// tickSeconds answers the period of the monitor's ticker, for the sites
+// currently configured.  The site list may be swapped by a refresh.
+func (m *Monitor) tickSeconds() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.conf.tickSeconds()
+}
+
+// interval answers the period after which the given site should next be
+// checked.  The fast interval, if any, applies while the site has never
+// succeeded, or is currently failing.
+func (m *Monitor) interval(site *Site, st *siteState) time.Duration {
+	secs := m.conf.HeartbeatSeconds
+	if site.IntervalSeconds > 0 {
+		secs = site.IntervalSeconds
+	}
+	if site.FastIntervalSeconds > 0 && (st.lastSuccessAt.IsZero() || st.consecutiveFailures > 0) {
+		secs = site.FastIntervalSeconds
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// isDue answers whether the given site should be checked in the current
+// sweep.  Half a tick of slack absorbs the ticker's jitter.
+func (m *Monitor) isDue(site *Site) bool {
+	due := true
+	slack := time.Duration(m.tickSeconds()) * time.Second / 2
+	m.withState(site, func(st *siteState) {
+		due = st.nextCheckAt.IsZero() || !m.now().Add(slack).Before(st.nextCheckAt)
+	})
+	return due
+}
+
+// applyGroups fills in the interval and recipients of each site from its
+// group, unless the site specifies its own.
+func (c *Config) applyGroups() {
+	groups := make(map[string]*GroupConfig, len(c.Groups))
+	for i := range c.Groups {
+		groups[c.Groups[i].Name] = &c.Groups[i]
+	}
+
+	for i := range c.Sites {
+		s := &c.Sites[i]
+		g, ok := groups[s.Group]
+		if !ok {
+			continue
+		}
+		if s.IntervalSeconds == 0 {
+			s.IntervalSeconds = g.IntervalSeconds
+		}
+		if len(s.Recipients) == 0 {
+			s.Recipients = g.Recipients
+		}
+	}
+}
+
+// initGroupSlots creates the semaphores that bound the concurrent checks
+// of the groups that specify a limit.
+func (m *Monitor) initGroupSlots() {
+	m.groupSlots = make(map[string]chan struct{})
+	for _, g := range m.conf.Groups {
+		if g.Concurrency > 0 {
+			m.groupSlots[g.Name] = make(chan struct{}, g.Concurrency)
+		}
+	}
+}
+
+// acquireSlot blocks until the given site may be checked, as per the
+// concurrency limit of its group, if any.  It answers the function that
+// releases the slot.
+func (m *Monitor) acquireSlot(site *Site) func() {
+	slots, ok := m.groupSlots[site.Group]
+	if !ok {
+		return func() {}
+	}
+	slots <- struct{}{}
+	return func() { <-slots }
+}
+
+// acquireMutex blocks until no other site of the mutex group of the given
+// site, if any, is being checked.  It answers the function that releases
+// the group.
+func (m *Monitor) acquireMutex(site *Site) func() {
+	if site.MutexGroup == "" {
+		return func() {}
+	}
+
+	v, _ := m.mutexes.LoadOrStore(site.MutexGroup, &sync.Mutex{})
+	mu := v.(*sync.Mutex)
+	if !mu.TryLock() {
+		tb := m.now()
+		zLog.Info("mutex",
+			zap.String("uri", site.Server),
+			zap.String("group", site.MutexGroup),
+			zap.String("status", "waiting"))
+		mu.Lock()
+		zLog.Info("mutex",
+			zap.String("uri", site.Server),
+			zap.String("group", site.MutexGroup),
+			zap.Int64("waitedMs", m.since(tb).Milliseconds()))
+	}
+	return mu.Unlock
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestInterval(t *testing.T) {
+	conf := &Config{HeartbeatSeconds: 60}
+	m, _ := newTestMonitor(conf)
+	errDown := errors.New("down")
+
+	tests := []struct {
+		name     string
+		site     Site
+		outcomes []error
+		want     time.Duration
+	}{
+		{"heartbeat, never checked", Site{}, nil, 60 * time.Second},
+		{"own interval", Site{IntervalSeconds: 300}, []error{nil}, 300 * time.Second},
+		{"fast, never succeeded", Site{IntervalSeconds: 300, FastIntervalSeconds: 10}, nil, 10 * time.Second},
+		{"fast, while failing", Site{IntervalSeconds: 300, FastIntervalSeconds: 10}, []error{nil, errDown}, 10 * time.Second},
+		{"normal, once recovered", Site{IntervalSeconds: 300, FastIntervalSeconds: 10}, []error{errDown, nil}, 300 * time.Second},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.site.Name = tt.name
+			st := &siteState{}
+			for _, err := range tt.outcomes {
+				m.recordResult(&tt.site, err)
+			}
+			m.withState(&tt.site, func(s *siteState) { st = s })
+			if got := m.interval(&tt.site, st); got != tt.want {
+				t.Errorf("#%d: interval %v, expected %v", i, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsDue(t *testing.T) {
+	// The tick is the GCD of 60 and 120 s; its half is the slack.
+	conf := &Config{HeartbeatSeconds: 60, Sites: []Site{{Name: "a", IntervalSeconds: 120}}}
+
+	tests := []struct {
+		name    string
+		checked bool
+		elapsed time.Duration
+		want    bool
+	}{
+		{"never checked", false, 0, true},
+		{"just checked", true, 0, false},
+		{"before the slack", true, 89 * time.Second, false},
+		{"within the slack", true, 90 * time.Second, true},
+		{"overdue", true, 10 * time.Minute, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, clk := newTestMonitor(conf)
+			site := &conf.Sites[0]
+			if tt.checked {
+				m.recordResult(site, nil)
+			}
+			clk.Advance(tt.elapsed)
+			if got := m.isDue(site); got != tt.want {
+				t.Errorf("due %v after %v, expected %v", got, tt.elapsed, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartupGrace(t *testing.T) {
+	m, clk := newTestMonitor(&Config{StartupGraceSeconds: 30})
+
+	for _, tt := range []struct {
+		advance time.Duration
+		want    bool
+	}{
+		{0, true},
+		{29 * time.Second, true},
+		{time.Second, false},
+	} {
+		clk.Advance(tt.advance)
+		if got := m.inStartupGrace(); got != tt.want {
+			t.Errorf("in grace %v at %v, expected %v", got, m.since(m.startedAt), tt.want)
+		}
+	}
+}
+
+func TestHealthzTick(t *testing.T) {
+	m, clk := newTestMonitor(&Config{HeartbeatSeconds: 300, Sites: []Site{{Name: "a", IntervalSeconds: 10}}})
+	m.lastSweepAt = m.now()
+
+	for _, tt := range []struct {
+		after time.Duration
+		want  int
+	}{
+		{20 * time.Second, http.StatusOK},
+		{time.Minute, http.StatusServiceUnavailable},
+	} {
+		clk.Advance(tt.after)
+		rec := httptest.NewRecorder()
+		m.handleHealthz(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		if rec.Code != tt.want {
+			t.Fatalf("status %d after %v, expected %d", rec.Code, tt.after, tt.want)
+		}
+	}
+}
+
+func TestCheckNowHonoursGroupSlots(t *testing.T) {
+	host, port := unreachableAddr(t)
+	site := Site{Name: "a", Protocol: "tcp", Server: host, Group: "g", TCPConfig: TCPConfig{Port: port}}
+	m, _ := newTestMonitor(&Config{HeartbeatSeconds: 60, Groups: []GroupConfig{{Name: "g", Concurrency: 1}}, Sites: []Site{site}})
+	m.initGroupSlots()
+
+	release := m.acquireSlot(&site)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		rec := httptest.NewRecorder()
+		m.handleCheck(rec, httptest.NewRequest(http.MethodPost, "/check?site="+site.key(), nil))
+	}()
+	select {
+	case <-done:
+		t.Fatal("out-of-band check ran while the group was at its limit")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	release()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("out-of-band check did not run once a slot was free")
+	}
+}
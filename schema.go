@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// maxSchemaErrors caps the number of schema violations reported.
+const maxSchemaErrors = 5
+
+// compileSchemas compiles the response schemas of all the sites, once.
+func (m *Monitor) compileSchemas() error {
+	m.schemas = make(map[string]*jsonschema.Schema)
+	for _, site := range m.conf.Sites {
+		f := site.HTTPConfig.ResponseSchemaFile
+		if f == "" || m.schemas[f] != nil {
+			continue
+		}
+
+		sch, err := jsonschema.NewCompiler().Compile(f)
+		if err != nil {
+			return fmt.Errorf("site '%s' : response schema : %w", site.Server, err)
+		}
+		m.schemas[f] = sch
+	}
+	return nil
+}
+
+// checkSchema validates the (capped) response body against the site's
+// response schema, if any.
+func (m *Monitor) checkSchema(site *Site, body []byte) error {
+	m.mu.Lock()
+	sch := m.schemas[site.HTTPConfig.ResponseSchemaFile]
+	m.mu.Unlock()
+	if sch == nil {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("response body is not valid JSON: %w", err)
+	}
+
+	err := sch.Validate(v)
+	var vErr *jsonschema.ValidationError
+	if !errors.As(err, &vErr) {
+		return err
+	}
+
+	// Report the specific violations, rather than the summary.
+	var msgs []string
+	for _, e := range vErr.BasicOutput().Errors {
+		if e.Error == "" || strings.HasPrefix(e.Error, "doesn't validate with") {
+			continue
+		}
+		msgs = append(msgs, fmt.Sprintf("%s: %s", e.InstanceLocation, e.Error))
+		if len(msgs) == maxSchemaErrors {
+			break
+		}
+	}
+	if len(msgs) == 0 {
+		msgs = append(msgs, vErr.Error())
+	}
+	return fmt.Errorf("response does not conform to schema: %s", strings.Join(msgs, "; "))
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net"
+	"os"
+
+	"go.uber.org/zap"
+)
+
+// sdNotify reports the given state to systemd, when running as a service
+// of type `notify`.  Elsewhere, it does nothing.
+func sdNotify(state string) {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return
+	}
+
+	conn, err := net.Dial("unixgram", addr)
+	if err != nil {
+		zLog.Warn("sd_notify",
+			zap.String("error", err.Error()))
+		return
+	}
+	defer conn.Close()
+
+	if _, err = conn.Write([]byte(state)); err != nil {
+		zLog.Warn("sd_notify",
+			zap.String("error", err.Error()))
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// startServer starts the embedded HTTP server on the configured status
+// port, if one is specified.  The port is bound before answering, so
+// that a port already in use is reported as an error.
+func (m *Monitor) startServer() error {
+	if m.conf.StatusPort == 0 {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/version", m.handleVersion)
+	mux.HandleFunc("/metrics", m.handleMetrics)
+	mux.HandleFunc("/healthz", m.handleHealthz)
+	mux.HandleFunc("/status", m.handleStatus)
+	mux.HandleFunc("/pause", m.handlePause(true))
+	mux.HandleFunc("/resume", m.handlePause(false))
+	mux.HandleFunc("/check", m.handleCheck)
+	mux.HandleFunc("/ack", m.handleAck)
+
+	addr := fmt.Sprintf(":%d", m.conf.StatusPort)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	m.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := m.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			zLog.Error("server",
+				zap.String("addr", addr),
+				zap.String("error", err.Error()))
+		}
+	}()
+
+	zLog.Info("server", zap.String("addr", addr))
+	return nil
+}
+
+// stopServer gracefully shuts the embedded HTTP server down, if it is
+// running.
+func (m *Monitor) stopServer() {
+	if m.server == nil {
+		return
+	}
+
+	ctx, cFunc := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cFunc()
+	if err := m.server.Shutdown(ctx); err != nil {
+		zLog.Error("server",
+			zap.String("error", err.Error()))
+	}
+}
+
+// writeJSON serialises the given value as the JSON body of the response.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// handleVersion answers the build information of the running program.
+func (m *Monitor) handleVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"version":   ProgramVersion,
+		"gitCommit": GitCommit,
+		"builtAt":   BuiltAt,
+		"goVersion": GoVersion,
+	})
+}
+
+// handleMetrics answers the monitor's own metrics, in Prometheus' text
+// exposition format.
+func (m *Monitor) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	sweeps, dur := m.sweeps, m.lastSweepMillis
+	m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintf(w, "# TYPE heartbeat_sweeps_total counter\n")
+	fmt.Fprintf(w, "heartbeat_sweeps_total %d\n", sweeps)
+	fmt.Fprintf(w, "# TYPE heartbeat_sweep_duration_milliseconds gauge\n")
+	fmt.Fprintf(w, "heartbeat_sweep_duration_milliseconds %d\n", dur)
+}
+
+// healthzTicks is the number of ticks within which a sweep must have
+// completed, for the monitor to be deemed healthy.
+const healthzTicks = 3
+
+// handleHealthz reports the monitor's own liveness: it is healthy if a
+// sweep has completed within the last `healthzTicks` ticks.  Before the
+// first sweep completes, the start time is used instead.
+func (m *Monitor) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	ref := m.lastSweepAt
+	tick := m.conf.tickSeconds()
+	m.mu.Unlock()
+	if ref.IsZero() {
+		ref = m.startedAt
+	}
+
+	limit := healthzTicks * time.Duration(tick) * time.Second
+	if since := m.since(ref); since > limit {
+		http.Error(w, fmt.Sprintf("no sweep completed in %s", since.Round(time.Second)), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// handlePause answers a handler that pauses or resumes alerts.
+func (m *Monitor) handlePause(paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		m.setPaused(paused, "http")
+		writeJSON(w, http.StatusOK, map[string]bool{"paused": paused})
+	}
+}
+
+// handleStatus answers the current status of all the sites.
+func (m *Monitor) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, m.statuses())
+}
+
+// CheckResult is the outcome of an out-of-band check.
+type CheckResult struct {
+	Site   string `json:"site"`
+	Up     bool   `json:"up"`
+	Error  string `json:"error,omitempty"`
+	Millis int64  `json:"millis"`
+}
+
+// handleCheck checks the named site immediately, and answers the outcome.
+// Alerts are raised as for a scheduled check.
+func (m *Monitor) handleCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := r.URL.Query().Get("site")
+	if name == "" {
+		http.Error(w, "site not specified", http.StatusBadRequest)
+		return
+	}
+	site, ok := m.findSite(name)
+	if !ok {
+		http.Error(w, "unknown site: "+name, http.StatusNotFound)
+		return
+	}
+
+	unlock := m.acquireMutex(&site)
+	release := m.acquireSlot(&site)
+	tb := m.now()
+	err := m.checkSite(site)
+	release()
+	unlock()
+	res := CheckResult{
+		Site:   site.key(),
+		Up:     err == nil,
+		Millis: m.since(tb).Milliseconds(),
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// DefAckMinutes is the duration of an acknowledgement that does not
+// specify one.
+const DefAckMinutes = 60
+
+// handleAck acknowledges the alerts of the named site: they are
+// suppressed until the site recovers, or the acknowledgement expires.
+// The site continues to be checked.
+func (m *Monitor) handleAck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := r.URL.Query().Get("site")
+	if name == "" {
+		http.Error(w, "site not specified", http.StatusBadRequest)
+		return
+	}
+	minutes := DefAckMinutes
+	if v := r.URL.Query().Get("minutes"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid minutes: "+v, http.StatusBadRequest)
+			return
+		}
+		minutes = n
+	}
+	site, ok := m.findSite(name)
+	if !ok {
+		http.Error(w, "unknown site: "+name, http.StatusNotFound)
+		return
+	}
+
+	until := m.now().Add(time.Duration(minutes) * time.Minute)
+	m.acknowledge(&site, until)
+	zLog.Info("ack",
+		zap.String("site", site.key()),
+		zap.Time("until", until))
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"site":       site.key(),
+		"ackedUntil": until,
+	})
+}
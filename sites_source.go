@@ -0,0 +1,196 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefSitesRefreshSeconds is the default interval between fetches of
+	// the site list from `Config.SitesSourceURL`.
+	DefSitesRefreshSeconds = 300
+
+	// DefSitesFetchTimeoutMillis bounds a single fetch of the site list.
+	DefSitesFetchTimeoutMillis = 10000
+
+	// maxSitesBytes caps the size of a fetched site list.
+	maxSitesBytes = 8 << 20
+)
+
+// sitesRefreshInterval answers the interval between fetches of the site
+// list.
+func (c *Config) sitesRefreshInterval() time.Duration {
+	if c.SitesRefreshSeconds > 0 {
+		return time.Duration(c.SitesRefreshSeconds) * time.Second
+	}
+	return DefSitesRefreshSeconds * time.Second
+}
+
+// fetchSites fetches the site list from the configured source.  The
+// response has the same shape as `Config.Sites`.
+func (m *Monitor) fetchSites() ([]Site, error) {
+	cl := &http.Client{Timeout: DefSitesFetchTimeoutMillis * time.Millisecond}
+	resp, err := cl.Get(m.conf.SitesSourceURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	var sites []Site
+	if err = json.NewDecoder(io.LimitReader(resp.Body, maxSitesBytes)).Decode(&sites); err != nil {
+		return nil, fmt.Errorf("decoding site list: %w", err)
+	}
+	return sites, nil
+}
+
+// refreshSites fetches the site list from `Config.SitesSourceURL`, when
+// due, in the background, so that a slow source does not delay the
+// sweep.  At most one fetch is in progress at any time.
+func (m *Monitor) refreshSites() {
+	if m.conf.SitesSourceURL == "" {
+		return
+	}
+	if !m.sitesFetchedAt.IsZero() && m.since(m.sitesFetchedAt) < m.conf.sitesRefreshInterval() {
+		return
+	}
+	if !m.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	m.sitesFetchedAt = m.now()
+
+	go func() {
+		defer m.refreshing.Store(false)
+		m.loadSites()
+	}()
+}
+
+// loadSites fetches the site list, and merges it with the statically
+// configured sites, swapping the merged list in.  Statically configured
+// sites take precedence over fetched ones with the same key.  Invalid
+// fetched sites are skipped.  Should the fetch fail, the last-known-good
+// list is retained.
+func (m *Monitor) loadSites() {
+	fetched, err := m.fetchSites()
+	if err != nil {
+		zLog.Error("sites",
+			zap.String("uri", m.conf.SitesSourceURL),
+			zap.String("error", err.Error()))
+		return
+	}
+	fetched = expandServers(fetched)
+
+	if m.staticSites == nil {
+		m.mu.Lock()
+		m.staticSites = append([]Site{}, m.conf.Sites...)
+		m.mu.Unlock()
+	}
+	groups := make(map[string]bool, len(m.conf.Groups))
+	for _, g := range m.conf.Groups {
+		groups[g.Name] = true
+	}
+	known := make(map[string]bool, len(m.notifiers))
+	for _, n := range m.notifiers {
+		known[n.Name()] = true
+	}
+
+	m.mu.Lock()
+	schemas := maps.Clone(m.schemas)
+	caPools := maps.Clone(m.caPools)
+	if caPools == nil {
+		caPools = make(map[string]*x509.CertPool)
+	}
+	m.mu.Unlock()
+
+	sites := append(make([]Site, 0, len(m.staticSites)+len(fetched)), m.staticSites...)
+	seen := make(map[string]bool, cap(sites))
+	for i := range sites {
+		seen[sites[i].key()] = true
+	}
+	for i := range fetched {
+		site := &fetched[i]
+		if seen[site.key()] {
+			continue
+		}
+		if err := m.validateFetchedSite(site, groups, known, schemas, caPools); err != nil {
+			zLog.Warn("sites",
+				zap.String("uri", site.Server),
+				zap.String("error", err.Error()))
+			continue
+		}
+		seen[site.key()] = true
+		sites = append(sites, *site)
+	}
+
+	if err := validateDependencies(sites); err != nil {
+		zLog.Error("sites",
+			zap.String("uri", m.conf.SitesSourceURL),
+			zap.String("error", err.Error()))
+		return
+	}
+
+	conf := *m.conf
+	conf.Sites = sites
+	conf.applyGroups()
+
+	m.mu.Lock()
+	m.conf.Sites = conf.Sites
+	m.schemas = schemas
+	m.caPools = caPools
+	m.mu.Unlock()
+	select {
+	case m.sitesChanged <- struct{}{}:
+	default:
+	}
+
+	zLog.Info("sites",
+		zap.String("uri", m.conf.SitesSourceURL),
+		zap.Int("fetched", len(fetched)),
+		zap.Int("sites", len(sites)))
+}
+
+// validateFetchedSite checks a fetched site as a configured one would be
+// checked at startup, compiling its response schema and loading its CA
+// pool, if any, into the given maps.
+func (m *Monitor) validateFetchedSite(site *Site, groups, notifiers map[string]bool, schemas map[string]*jsonschema.Schema, caPools map[string]*x509.CertPool) error {
+	if err := site.validate(); err != nil {
+		return err
+	}
+	if site.Group != "" && !groups[site.Group] {
+		return fmt.Errorf("%w: unknown group: %s", ErrConfig, site.Group)
+	}
+	for _, name := range site.Notifiers {
+		if !notifiers[name] {
+			return fmt.Errorf("%w: unknown notifier: %s", ErrConfig, name)
+		}
+	}
+
+	if k := caPoolKey(site); k != "" && caPools[k] == nil {
+		pool, err := loadCAPool(site.HTTPConfig.CACertFile, site.HTTPConfig.CACertDir)
+		if err != nil {
+			return fmt.Errorf("CA certificates : %w", err)
+		}
+		caPools[k] = pool
+	}
+
+	f := site.HTTPConfig.ResponseSchemaFile
+	if f == "" || schemas[f] != nil {
+		return nil
+	}
+	sch, err := jsonschema.NewCompiler().Compile(f)
+	if err != nil {
+		return fmt.Errorf("response schema : %w", err)
+	}
+	schemas[f] = sch
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRefreshSitesInBackground(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte(`[{"name": "fetched", "protocol": "tcp", "server": "h", "tcp": {"port": 22}}]`))
+	}))
+	defer srv.Close()
+
+	static := Site{Name: "static", Protocol: "tcp", Server: "h", TCPConfig: TCPConfig{Port: 80}}
+	m, _ := newTestMonitor(&Config{HeartbeatSeconds: 60, SitesSourceURL: srv.URL, Sites: []Site{static}})
+
+	tb := time.Now()
+	m.refreshSites()
+	if d := time.Since(tb); d > 100*time.Millisecond {
+		t.Fatalf("refresh held the sweep for %v", d)
+	}
+	if n := len(m.statuses()); n != 1 {
+		t.Fatalf("%d sites before the fetch completed, expected 1", n)
+	}
+
+	close(release)
+	for deadline := time.Now().Add(5 * time.Second); m.refreshing.Load(); {
+		if time.Now().After(deadline) {
+			t.Fatal("fetch did not complete")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, ok := m.findSite("fetched"); !ok {
+		t.Fatal("fetched site not merged")
+	}
+	if _, ok := m.findSite("static"); !ok {
+		t.Fatal("static site lost")
+	}
+}
+
+func TestRefreshedSitesTick(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"name": "fetched", "protocol": "tcp", "server": "h", "intervalSeconds": 15, "tcp": {"port": 22}}]`))
+	}))
+	defer srv.Close()
+
+	static := Site{Name: "static", Protocol: "tcp", Server: "h", TCPConfig: TCPConfig{Port: 80}}
+	m, _ := newTestMonitor(&Config{HeartbeatSeconds: 60, SitesSourceURL: srv.URL, Sites: []Site{static}})
+	m.sitesChanged = make(chan struct{}, 1)
+
+	// Sweeps consult the schedule while the sites are swapped.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			m.isDue(&static)
+		}
+	}()
+	m.loadSites()
+	<-done
+
+	select {
+	case <-m.sitesChanged:
+	default:
+		t.Fatal("swap of the sites not signalled")
+	}
+	if tick := m.tickSeconds(); tick != 15 {
+		t.Fatalf("tick of %d s, expected 15", tick)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// sourceAddress answers the local IP address from which checks of the
+// given site should originate; empty for the default interface.
+func (m *Monitor) sourceAddress(site *Site) string {
+	if site.SourceAddress != "" {
+		return site.SourceAddress
+	}
+	return m.conf.SourceAddress
+}
+
+// dialer answers a dialer with the given timeout, bound to the source
+// address of the given site, if any.
+func (m *Monitor) dialer(site *Site, timeout time.Duration) *net.Dialer {
+	d := &net.Dialer{Timeout: timeout}
+	if src := m.sourceAddress(site); src != "" {
+		d.LocalAddr = &net.TCPAddr{IP: net.ParseIP(src)}
+	}
+	return d
+}
+
+// pqDialer adapts a dialer to the Postgres driver.
+type pqDialer struct {
+	*net.Dialer
+}
+
+// DialTimeout dials the given address with the given timeout.
+func (d pqDialer) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	nd := *d.Dialer
+	nd.Timeout = timeout
+	return nd.Dial(network, address)
+}
+
+var (
+	mysqlNetsMu sync.Mutex
+	mysqlNets   = make(map[string]bool)
+)
+
+// mysqlNet answers the name of the MySQL driver network that dials from
+// the source address of the given site, registering it as necessary.
+func (m *Monitor) mysqlNet(site *Site) string {
+	src := m.sourceAddress(site)
+	if src == "" {
+		return "tcp"
+	}
+
+	name := "tcp@" + src
+	mysqlNetsMu.Lock()
+	defer mysqlNetsMu.Unlock()
+	if !mysqlNets[name] {
+		d := &net.Dialer{LocalAddr: &net.TCPAddr{IP: net.ParseIP(src)}}
+		mysql.RegisterDialContext(name, func(ctx context.Context, addr string) (net.Conn, error) {
+			return d.DialContext(ctx, "tcp", addr)
+		})
+		mysqlNets[name] = true
+	}
+	return name
+}
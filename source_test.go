@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDialerSourceAddress(t *testing.T) {
+	tests := []struct {
+		name string
+		conf string
+		site string
+		want string
+	}{
+		{"none", "", "", ""},
+		{"global", "127.0.0.2", "", "127.0.0.2"},
+		{"site", "", "127.0.0.3", "127.0.0.3"},
+		{"site overrides global", "127.0.0.2", "127.0.0.3", "127.0.0.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := newTestMonitor(&Config{SourceAddress: tt.conf})
+			d := m.dialer(&Site{SourceAddress: tt.site}, time.Second)
+			if d.Timeout != time.Second {
+				t.Fatalf("timeout %v, expected %v", d.Timeout, time.Second)
+			}
+			if tt.want == "" {
+				if d.LocalAddr != nil {
+					t.Fatalf("local address %v, expected none", d.LocalAddr)
+				}
+				return
+			}
+			addr, ok := d.LocalAddr.(*net.TCPAddr)
+			if !ok || addr.IP.String() != tt.want {
+				t.Fatalf("local address %v, expected %s", d.LocalAddr, tt.want)
+			}
+		})
+	}
+}
+
+func TestDatabaseSourceAddress(t *testing.T) {
+	const src = "127.0.0.2"
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	// Not every platform routes all of 127/8 to the loopback interface.
+	probe, err := (&net.Dialer{LocalAddr: &net.TCPAddr{IP: net.ParseIP(src)}}).Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Skipf("cannot dial from %s: %v", src, err)
+	}
+	probe.Close()
+	if conn, err := l.Accept(); err == nil {
+		conn.Close()
+	}
+
+	accepted := make(chan net.Addr, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn.RemoteAddr()
+			conn.Close()
+		}
+	}()
+	port := l.Addr().(*net.TCPAddr).Port
+
+	tests := []struct {
+		name string
+		site Site
+	}{
+		{"postgres", Site{Protocol: "postgres", PostgresConfig: PostgresConfig{Port: port}}},
+		{"clickhouse", Site{Protocol: "clickhouse", ClickHouseConfig: ClickHouseConfig{Port: port}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := newTestMonitor(&Config{})
+			site := tt.site
+			site.Server = "127.0.0.1"
+			site.SourceAddress = src
+			site.TimeoutMillis = 500
+			if err := m.isServerUp(context.Background(), &site); err == nil {
+				t.Fatal("expected a failed check")
+			}
+
+			select {
+			case addr := <-accepted:
+				if ip := addr.(*net.TCPAddr).IP.String(); ip != src {
+					t.Fatalf("connected from %s, expected %s", ip, src)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("no connection made")
+			}
+			// Drain the connections of any retries.
+			for len(accepted) > 0 {
+				<-accepted
+			}
+		})
+	}
+}
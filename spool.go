@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"go.uber.org/zap"
+)
+
+// DefSpoolMaxFiles is used in case of no specification in config.
+const DefSpoolMaxFiles = 1000
+
+// Mechanisms of SMTP authentication.  Each spooled email records the one
+// with which it was first sent, so that it is replayed likewise.
+const (
+	smtpAuthLogin = "login"
+	smtpAuthPlain = "plain"
+)
+
+// smtpAuth answers the authenticator for the given mechanism; `LOGIN`,
+// if unknown.
+func (m *Monitor) smtpAuth(mech string) smtp.Auth {
+	if mech == smtpAuthPlain {
+		return smtp.PlainAuth("", m.conf.Sender.Username, m.conf.Sender.Password, m.conf.Sender.Server)
+	}
+	return LoginAuth(m.conf.Sender.Username, m.conf.Sender.Password)
+}
+
+// spooledMail is a composed alert email that could not be sent.
+type spooledMail struct {
+	Auth       string   `json:"auth"` // mechanism; `LOGIN`, if absent
+	Recipients []string `json:"recipients"`
+	Msg        []byte   `json:"msg"`
+}
+
+// spool writes the given email to the spool directory, if one is
+// configured, so that it can be sent once the relay is reachable again.
+// The oldest spooled emails are dropped when the spool is full.
+func (m *Monitor) spool(mech string, recipients []string, msg []byte) {
+	dir := m.conf.Sender.SpoolDir
+	if dir == "" {
+		return
+	}
+
+	m.spoolMu.Lock()
+	defer m.spoolMu.Unlock()
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		zLog.Error("spool",
+			zap.String("dir", dir),
+			zap.String("error", err.Error()))
+		return
+	}
+
+	limit := m.conf.Sender.SpoolMaxFiles
+	if limit <= 0 {
+		limit = DefSpoolMaxFiles
+	}
+	if names, err := spoolFiles(dir); err == nil && len(names) >= limit {
+		for _, name := range names[:len(names)-limit+1] {
+			os.Remove(name)
+			zLog.Warn("spool",
+				zap.String("file", name),
+				zap.String("error", "spool full; oldest alert dropped"))
+		}
+	}
+
+	buf, _ := json.Marshal(spooledMail{Auth: mech, Recipients: recipients, Msg: msg})
+	name := filepath.Join(dir, fmt.Sprintf("%020d.json", m.now().UnixNano()))
+	if err := os.WriteFile(name, buf, 0o600); err != nil {
+		zLog.Error("spool",
+			zap.String("file", name),
+			zap.String("error", err.Error()))
+		return
+	}
+	zLog.Warn("spool",
+		zap.String("file", name),
+		zap.Strings("recipients", recipients))
+}
+
+// sendOrSpool sends the given email, authenticating with the given
+// mechanism, and spools it upon failure.  A successful send indicates
+// that the relay is reachable; hence, the spool is flushed.
+func (m *Monitor) sendOrSpool(mech string, recipients []string, msg []byte) error {
+	err := m.sendMail(m.smtpAuth(mech), recipients, msg)
+	if err != nil {
+		m.spool(mech, recipients, msg)
+		return err
+	}
+
+	go m.flushSpool()
+	return nil
+}
+
+// flushSpool sends the spooled emails, oldest first, stopping at the
+// first failure.  Concurrent flushes are skipped.
+func (m *Monitor) flushSpool() {
+	dir := m.conf.Sender.SpoolDir
+	if dir == "" || !m.spoolMu.TryLock() {
+		return
+	}
+	defer m.spoolMu.Unlock()
+
+	names, err := spoolFiles(dir)
+	if err != nil || len(names) == 0 {
+		return
+	}
+
+	for _, name := range names {
+		buf, err := os.ReadFile(name)
+		if err != nil {
+			continue
+		}
+		var sm spooledMail
+		if err = json.Unmarshal(buf, &sm); err != nil {
+			zLog.Error("spool",
+				zap.String("file", name),
+				zap.String("error", err.Error()))
+			os.Remove(name)
+			continue
+		}
+		if err = m.sendMail(m.smtpAuth(sm.Auth), sm.Recipients, sm.Msg); err != nil {
+			zLog.Warn("spool",
+				zap.String("file", name),
+				zap.String("error", err.Error()))
+			return
+		}
+		os.Remove(name)
+		zLog.Info("spool",
+			zap.String("file", name),
+			zap.String("flushed", "sent"))
+	}
+}
+
+// spoolFiles answers the paths of the spooled emails, oldest first.
+func spoolFiles(dir string) ([]string, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
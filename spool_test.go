@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTP is an SMTP server that accepts every message, recording it
+// and the authentication mechanism of each.
+type fakeSMTP struct {
+	addr  string
+	mu    sync.Mutex
+	mechs []string
+	msgs  []string
+}
+
+// newFakeSMTP starts a fake SMTP server, offering `PLAIN` and `LOGIN`
+// authentication.
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	s := &fakeSMTP{addr: l.Addr().String()}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	readLine := func() (string, bool) {
+		line, err := r.ReadString('\n')
+		return strings.TrimRight(line, "\r\n"), err == nil
+	}
+
+	reply("220 fake")
+	for {
+		line, ok := readLine()
+		if !ok {
+			return
+		}
+		cmd := strings.ToUpper(strings.Fields(line + " x")[0])
+		switch cmd {
+		case "EHLO", "HELO":
+			reply("250-fake")
+			reply("250 AUTH PLAIN LOGIN")
+		case "AUTH":
+			mech := strings.ToUpper(strings.Fields(line)[1])
+			if mech == "LOGIN" {
+				reply("334 VXNlcm5hbWU6") // Username:
+				readLine()
+				reply("334 UGFzc3dvcmQ6") // Password:
+				readLine()
+			}
+			s.mu.Lock()
+			s.mechs = append(s.mechs, mech)
+			s.mu.Unlock()
+			reply("235 authenticated")
+		case "DATA":
+			reply("354 go ahead")
+			var msg strings.Builder
+			for {
+				l, ok := readLine()
+				if !ok || l == "." {
+					break
+				}
+				msg.WriteString(l + "\n")
+			}
+			s.mu.Lock()
+			s.msgs = append(s.msgs, msg.String())
+			s.mu.Unlock()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func (s *fakeSMTP) mechanisms() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.mechs...)
+}
+
+func (s *fakeSMTP) messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.msgs...)
+}
+
+func TestFlushSpoolAuth(t *testing.T) {
+	srv := newFakeSMTP(t)
+	dir := t.TempDir()
+	m, clk := newTestMonitor(&Config{Sender: SenderConfig{
+		Server:   "127.0.0.1",
+		Username: "hb@example.com",
+		Password: "secret",
+		SpoolDir: dir,
+		Attempts: 1,
+	}})
+	m.mailServer = srv.addr
+
+	msg := []byte("Subject: test\r\n\r\nbody\r\n")
+	m.spool(smtpAuthPlain, []string{"ops@example.com"}, msg)
+	clk.Advance(time.Second)
+	m.spool(smtpAuthLogin, []string{"ops@example.com"}, msg)
+	clk.Advance(time.Second)
+	// Spooled before the mechanism was recorded.
+	old := `{"recipients": ["ops@example.com"], "msg": "U3ViamVjdDogdGVzdA0KDQpib2R5DQo="}`
+	if err := os.WriteFile(dir+"/99999999999999999999.json", []byte(old), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	m.flushSpool()
+	if got, want := strings.Join(srv.mechanisms(), ","), "PLAIN,LOGIN,LOGIN"; got != want {
+		t.Fatalf("authenticated with %s, expected %s", got, want)
+	}
+	if names, _ := spoolFiles(dir); len(names) != 0 {
+		t.Fatalf("%d emails left in the spool", len(names))
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// siteState holds the information about a site that has to be carried
+// across sweeps.
+type siteState struct {
+	lastSuccessAt       time.Time
+	lastFailureAt       time.Time
+	lastError           string
+	consecutiveFailures int
+	nextCheckAt         time.Time
+	healthySince        time.Time // first success since the last failure
+	downSince           time.Time // first failure of the current outage
+	ackedUntil          time.Time // alerts suppressed until then, or recovery
+
+	contentHash string
+
+	latencies  []int64 // ring buffer of recent total latencies
+	latencyPos int
+
+	latencyEMA float64 // exponential moving average of total latency
+	emaSamples int64
+
+	latencyMean    float64 // rolling mean and variance of total latency
+	latencyVar     float64
+	latencySamples int64
+
+	digestChecks int // over the current digest period
+	digestUp     int
+	digestMillis int64
+	digestAlerts int
+}
+
+// key answers a string that identifies the given site uniquely in the
+// configuration.  Unnamed sites are identified by their protocol, server,
+// port and path; sites that differ otherwise have to be named.
+func (s *Site) key() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	var path string
+	switch protocolFamilies[s.Protocol] {
+	case "http":
+		path = s.HTTPConfig.URL
+	case "websocket":
+		path = strings.TrimPrefix(s.WebSocketConfig.Path, "/")
+	}
+	return fmt.Sprintf("%s://%s:%d/%s", s.Protocol, s.Server, s.port(), path)
+}
+
+// port answers the port specified for the site's protocol; zero, if none.
+func (s *Site) port() int {
+	switch protocolFamilies[s.Protocol] {
+	case "http":
+		return s.HTTPConfig.Port
+	case "mysql":
+		return s.MySQLConfig.Port
+	case "sqlserver":
+		return s.SQLServerConfig.Port
+	case "websocket":
+		return s.WebSocketConfig.Port
+	case "mqtt":
+		return s.MQTTConfig.Port
+	case "memcached":
+		return s.MemcachedConfig.Port
+	case "nats":
+		return s.NATSConfig.Port
+	case "ssh":
+		return s.SSHConfig.Port
+	case "postgres":
+		return s.PostgresConfig.Port
+	case "clickhouse":
+		return s.ClickHouseConfig.Port
+	case "ldap":
+		return s.LDAPConfig.Port
+	case "consul":
+		return s.ConsulConfig.Port
+	case "etcd":
+		return s.EtcdConfig.Port
+	case "tcp":
+		return s.TCPConfig.Port
+	case "amqp":
+		return s.AMQPConfig.Port
+	case "cassandra":
+		return s.CassandraConfig.Port
+	case "docker":
+		return s.DockerConfig.Port
+	case "kubernetes":
+		return s.KubernetesConfig.Port
+	case "grpc":
+		return s.GRPCConfig.Port
+	case "oracle":
+		return s.OracleConfig.Port
+	case "ntp":
+		return s.NTPConfig.Port
+	}
+	return 0
+}
+
+// findSite answers the configured site with the given key.
+func (m *Monitor) findSite(key string) (Site, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.conf.Sites {
+		if m.conf.Sites[i].key() == key {
+			return m.conf.Sites[i], true
+		}
+	}
+	return Site{}, false
+}
+
+// withState invokes the given function with the state of the given site,
+// while holding the state lock.  State is created if not present.
+func (m *Monitor) withState(site *Site, fn func(st *siteState)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.states == nil {
+		m.states = make(map[string]*siteState)
+	}
+	st, ok := m.states[site.key()]
+	if !ok {
+		st = &siteState{}
+		m.states[site.key()] = st
+	}
+	fn(st)
+}
+
+// recordLatency adds the given latency to the site's rolling window of
+// the given size, and answers the 95th percentile of the window, once it
+// is full.
+func (st *siteState) recordLatency(ms int64, size int) (int64, bool) {
+	if cap(st.latencies) != size {
+		st.latencies = make([]int64, 0, size)
+		st.latencyPos = 0
+	}
+
+	if len(st.latencies) < size {
+		st.latencies = append(st.latencies, ms)
+	} else {
+		st.latencies[st.latencyPos] = ms
+	}
+	st.latencyPos = (st.latencyPos + 1) % size
+
+	if len(st.latencies) < size {
+		return 0, false
+	}
+	return percentile(st.latencies, 95), true
+}
+
+// recordEMA folds the given latency into the site's exponential moving
+// average, using the given smoothing factor, and answers the average.
+// The first sample seeds the average.
+func (st *siteState) recordEMA(ms float64, alpha float64) float64 {
+	if st.emaSamples == 0 {
+		st.latencyEMA = ms
+	} else {
+		st.latencyEMA = alpha*ms + (1-alpha)*st.latencyEMA
+	}
+	st.emaSamples++
+	return st.latencyEMA
+}
+
+// DefAnomalyMinSamples is used in case of no specification in config.
+const DefAnomalyMinSamples = 30
+
+// recordAnomaly compares the given latency to the site's rolling mean
+// and standard deviation, and answers if it exceeds the mean by more
+// than k standard deviations.  No anomaly is reported until the given
+// number of samples is seen.  The latency is then folded into the
+// exponentially weighted mean and variance, using the given smoothing
+// factor.
+func (st *siteState) recordAnomaly(ms, alpha, k float64, minSamples int64) (mean, sd float64, anomalous bool) {
+	mean, sd = st.latencyMean, math.Sqrt(st.latencyVar)
+	anomalous = st.latencySamples >= minSamples && ms > mean+k*sd
+
+	if st.latencySamples == 0 {
+		st.latencyMean = ms
+	} else {
+		d := ms - st.latencyMean
+		st.latencyMean += alpha * d
+		st.latencyVar = (1 - alpha) * (st.latencyVar + alpha*d*d)
+	}
+	st.latencySamples++
+	return mean, sd, anomalous
+}
+
+// DefLatencyEMAAlpha is used in case of no specification in config.
+const DefLatencyEMAAlpha = 0.2
+
+// emaAlpha answers the smoothing factor of the latency moving averages.
+func (c *Config) emaAlpha() float64 {
+	if c.LatencyEMAAlpha <= 0 || c.LatencyEMAAlpha > 1 {
+		return DefLatencyEMAAlpha
+	}
+	return c.LatencyEMAAlpha
+}
+
+// percentile answers the p-th percentile of the given samples, using
+// the nearest-rank method.
+func percentile(samples []int64, p int) int64 {
+	sorted := make([]int64, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// recordResult updates the state of the given site with the outcome of
+// its check.  Should the check end an outage, it answers the time at
+// which the outage began; else, the zero time.
+func (m *Monitor) recordResult(site *Site, err error) (downSince time.Time) {
+	m.withState(site, func(st *siteState) {
+		defer func() {
+			st.nextCheckAt = m.now().Add(m.interval(site, st))
+		}()
+
+		if err == nil {
+			st.lastSuccessAt = m.now()
+			st.consecutiveFailures = 0
+			st.ackedUntil = time.Time{}
+			if st.healthySince.IsZero() {
+				st.healthySince = st.lastSuccessAt
+			}
+			downSince, st.downSince = st.downSince, time.Time{}
+			return
+		}
+		st.lastFailureAt = m.now()
+		if st.consecutiveFailures == 0 {
+			st.downSince = st.lastFailureAt
+		}
+		st.healthySince = time.Time{}
+		st.lastError = err.Error()
+		st.consecutiveFailures++
+	})
+	return downSince
+}
+
+// SiteStatus is the externally-visible status of a site.
+type SiteStatus struct {
+	Site                string     `json:"site"`
+	Protocol            string     `json:"protocol"`
+	Server              string     `json:"server"`
+	Up                  bool       `json:"up"`
+	LastSuccessAt       *time.Time `json:"lastSuccessAt,omitempty"`
+	LastFailureAt       *time.Time `json:"lastFailureAt,omitempty"`
+	LastError           string     `json:"lastError,omitempty"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	AckedUntil          *time.Time `json:"ackedUntil,omitempty"`
+}
+
+// statuses answers the current status of all the configured sites, in
+// configuration order.
+func (m *Monitor) statuses() []SiteStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	res := make([]SiteStatus, 0, len(m.conf.Sites))
+	for i := range m.conf.Sites {
+		site := &m.conf.Sites[i]
+		ss := SiteStatus{
+			Site:     site.key(),
+			Protocol: site.Protocol,
+			Server:   site.Server,
+		}
+		if st, ok := m.states[site.key()]; ok {
+			ss.Up = st.consecutiveFailures == 0 && !st.lastSuccessAt.IsZero()
+			if !st.lastSuccessAt.IsZero() {
+				t := st.lastSuccessAt
+				ss.LastSuccessAt = &t
+			}
+			if !st.lastFailureAt.IsZero() {
+				t := st.lastFailureAt
+				ss.LastFailureAt = &t
+			}
+			ss.LastError = st.lastError
+			ss.ConsecutiveFailures = st.consecutiveFailures
+			if st.ackedUntil.After(m.now()) {
+				t := st.ackedUntil
+				ss.AckedUntil = &t
+			}
+		}
+		res = append(res, ss)
+	}
+	return res
+}
+
+// acknowledge suppresses the alerts of the given site until the given
+// time, or until it recovers, whichever is earlier.
+func (m *Monitor) acknowledge(site *Site, until time.Time) {
+	m.withState(site, func(st *siteState) {
+		st.ackedUntil = until
+	})
+}
+
+// isAcknowledged answers if the alerts of the given site are currently
+// acknowledged.
+func (m *Monitor) isAcknowledged(site *Site) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st, ok := m.states[site.key()]
+	return ok && st.ackedUntil.After(m.now())
+}
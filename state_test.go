@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRecordResult(t *testing.T) {
+	m, clk := newTestMonitor(&Config{HeartbeatSeconds: 60})
+	site := &Site{Name: "a", FastIntervalSeconds: 10}
+	errDown := errors.New("down")
+
+	tests := []struct {
+		advance      time.Duration
+		err          error
+		failures     int
+		downSince    time.Duration // since the epoch, as answered; -1 if zero
+		healthySince time.Duration // since the epoch; -1 if zero
+		next         time.Duration // since the check
+	}{
+		{0, nil, 0, -1, 0, 60 * time.Second},
+		{time.Minute, errDown, 1, -1, -1, 10 * time.Second},
+		{10 * time.Second, errDown, 2, -1, -1, 10 * time.Second},
+		{10 * time.Second, nil, 0, time.Minute, 80 * time.Second, 60 * time.Second},
+		{time.Minute, nil, 0, -1, 80 * time.Second, 60 * time.Second},
+	}
+	at := func(d time.Duration) time.Time {
+		if d < 0 {
+			return time.Time{}
+		}
+		return testEpoch.Add(d)
+	}
+	for i, tt := range tests {
+		clk.Advance(tt.advance)
+		downSince := m.recordResult(site, tt.err)
+		if !downSince.Equal(at(tt.downSince)) {
+			t.Errorf("#%d: outage since %v, expected %v", i, downSince, at(tt.downSince))
+		}
+		m.withState(site, func(st *siteState) {
+			if st.consecutiveFailures != tt.failures {
+				t.Errorf("#%d: %d failures, expected %d", i, st.consecutiveFailures, tt.failures)
+			}
+			if !st.healthySince.Equal(at(tt.healthySince)) {
+				t.Errorf("#%d: healthy since %v, expected %v", i, st.healthySince, at(tt.healthySince))
+			}
+			if want := clk.Now().Add(tt.next); !st.nextCheckAt.Equal(want) {
+				t.Errorf("#%d: next check at %v, expected %v", i, st.nextCheckAt, want)
+			}
+		})
+	}
+}
+
+func TestAcknowledgementExpires(t *testing.T) {
+	m, clk := newTestMonitor(&Config{HeartbeatSeconds: 60})
+	site := &Site{Name: "a"}
+
+	m.recordResult(site, errors.New("down"))
+	m.acknowledge(site, clk.Now().Add(time.Hour))
+	clk.Advance(59 * time.Minute)
+	if !m.isAcknowledged(site) {
+		t.Fatal("acknowledgement expired early")
+	}
+	clk.Advance(time.Minute)
+	if m.isAcknowledged(site) {
+		t.Fatal("acknowledgement did not expire")
+	}
+
+	m.acknowledge(site, clk.Now().Add(time.Hour))
+	m.recordResult(site, nil)
+	if m.isAcknowledged(site) {
+		t.Fatal("acknowledgement survived recovery")
+	}
+}
+
+func TestSiteKey(t *testing.T) {
+	tests := []struct {
+		site Site
+		want string
+	}{
+		{Site{Name: "db", Protocol: "mysql", Server: "h"}, "db"},
+		{Site{Protocol: "tcp", Server: "h", TCPConfig: TCPConfig{Port: 22}}, "tcp://h:22/"},
+		{Site{Protocol: "tcp", Server: "h", TCPConfig: TCPConfig{Port: 80}}, "tcp://h:80/"},
+		{Site{Protocol: "https", Server: "h", HTTPConfig: HTTPConfig{Port: 8443, URL: "health"}}, "https://h:8443/health"},
+		{Site{Protocol: "wss", Server: "h", WebSocketConfig: WebSocketConfig{Path: "/ws"}}, "wss://h:0/ws"},
+		{Site{Protocol: "postgres", Server: "h", PostgresConfig: PostgresConfig{Port: 5432}}, "postgres://h:5432/"},
+	}
+	for _, tt := range tests {
+		if got := tt.site.key(); got != tt.want {
+			t.Errorf("key %q, expected %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+)
+
+// tracerName identifies the spans emitted by the monitor.
+const tracerName = "github.com/js-ojus/heartbeat.go"
+
+// initTracing sets up the export of check spans to the configured OTLP
+// collector.  Tracing is a no-op in the absence of an endpoint.
+func (m *Monitor) initTracing() error {
+	tc := &m.conf.Tracing
+	if tc.Endpoint == "" {
+		m.tracer = noop.NewTracerProvider().Tracer(tracerName)
+		return nil
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(tc.Endpoint)}
+	if tc.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exp, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return err
+	}
+	name := tc.ServiceName
+	if name == "" {
+		name = "heartbeat"
+	}
+	m.tracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(resource.NewSchemaless(
+			semconv.ServiceName(name),
+			semconv.HostName(m.hostname))))
+	m.tracer = m.tracerProvider.Tracer(tracerName)
+	return nil
+}
+
+// stopTracing flushes the pending spans, if tracing is enabled.
+func (m *Monitor) stopTracing() {
+	if m.tracerProvider == nil {
+		return
+	}
+
+	ctx, cFunc := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cFunc()
+	if err := m.tracerProvider.Shutdown(ctx); err != nil {
+		zLog.Error("tracing",
+			zap.String("error", err.Error()))
+	}
+}
+
+// startCheckSpan starts the span that covers the check of the given site.
+func (m *Monitor) startCheckSpan(site *Site) (context.Context, trace.Span) {
+	return m.tracer.Start(context.Background(), "check "+site.Protocol,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("site", site.key()),
+			attribute.String("server", site.Server),
+			attribute.String("protocol", site.Protocol)))
+}
+
+// endCheckSpan records the outcome of a check in its span, and ends it.
+func endCheckSpan(span trace.Span, err error) {
+	if err != nil {
+		span.SetAttributes(attribute.String("status", "down"))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetAttributes(attribute.String("status", "up"))
+	}
+	span.End()
+}
+
+// tracePhase records a completed phase of a check as a child span of the
+// check's span.  Phases that did not occur are skipped.
+func tracePhase(ctx context.Context, name string, start, end time.Time) {
+	if start.IsZero() || end.IsZero() {
+		return
+	}
+
+	parent := trace.SpanFromContext(ctx)
+	_, span := parent.TracerProvider().Tracer(tracerName).Start(ctx, name, trace.WithTimestamp(start))
+	span.End(trace.WithTimestamp(end))
+}
+
+// traceHTTPStatus records the given HTTP status code in the check's span.
+func traceHTTPStatus(ctx context.Context, code int) {
+	trace.SpanFromContext(ctx).SetAttributes(semconv.HTTPResponseStatusCode(code))
+}
+
+// latest answers the later of the given instants.
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -1,65 +1,485 @@
 package main
 
 import (
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"net"
+	"net/http"
 	"net/smtp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // SenderConfig specifies the configuration to use for sending alerts.
 type SenderConfig struct {
-	Server      string `json:"server"`
-	Port        int    `json:"port"`
-	Username    string `json:"username"`
-	Password    string `json:"password"`
+	Server        string `json:"server"`
+	Port          int    `json:"port"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	DisplayName   string `json:"displayName"`
+	Attempts      int    `json:"attempts"`
+	SpoolDir      string `json:"spoolDir"`      // unsent alerts are kept here
+	SpoolMaxFiles int    `json:"spoolMaxFiles"` // default 1000
+}
+
+// FromIdentity specifies the sender identity shown in alert emails.
+type FromIdentity struct {
+	Address     string `json:"address"`
 	DisplayName string `json:"displayName"`
 }
 
 // Site specifies a site whose heartbeat has to be monitored.
 type Site struct {
-	Server                  string          `json:"server"`
-	Protocol                string          `json:"protocol"`
-	HTTPConfig              HTTPConfig      `json:"http"`
-	MySQLConfig             MySQLConfig     `json:"mysql"`
-	SQLServerConfig         SQLServerConfig `json:"sqlserver"`
-	ConnectionTimeoutMillis int64           `json:"connectionTimeoutMillis"`
-	TimeoutMillis           int64           `json:"timeoutMillis"`
-	Recipients              []string        `json:"recipients"`
+	Name                    string           `json:"name"`
+	Server                  string           `json:"server"`
+	Servers                 []string         `json:"servers"` // each checked as a site of its own, in addition to `server`
+	Protocol                string           `json:"protocol"`
+	HTTPConfig              HTTPConfig       `json:"http"`
+	MySQLConfig             MySQLConfig      `json:"mysql"`
+	SQLServerConfig         SQLServerConfig  `json:"sqlserver"`
+	WebSocketConfig         WebSocketConfig  `json:"websocket"`
+	MQTTConfig              MQTTConfig       `json:"mqtt"`
+	MemcachedConfig         MemcachedConfig  `json:"memcached"`
+	NATSConfig              NATSConfig       `json:"nats"`
+	SSHConfig               SSHConfig        `json:"ssh"`
+	PostgresConfig          PostgresConfig   `json:"postgres"`
+	ClickHouseConfig        ClickHouseConfig `json:"clickhouse"`
+	LDAPConfig              LDAPConfig       `json:"ldap"`
+	ConsulConfig            ConsulConfig     `json:"consul"`
+	EtcdConfig              EtcdConfig       `json:"etcd"`
+	TCPConfig               TCPConfig        `json:"tcp"`
+	AMQPConfig              AMQPConfig       `json:"amqp"`
+	CassandraConfig         CassandraConfig  `json:"cassandra"`
+	DockerConfig            DockerConfig     `json:"docker"`
+	KubernetesConfig        KubernetesConfig `json:"kubernetes"`
+	GRPCConfig              GRPCConfig       `json:"grpc"`
+	OracleConfig            OracleConfig     `json:"oracle"`
+	NTPConfig               NTPConfig        `json:"ntp"`
+	ConnectionTimeoutMillis int64            `json:"connectionTimeoutMillis"`
+	TimeoutMillis           int64            `json:"timeoutMillis"`
+	ResolverTimeoutMillis   int              `json:"resolverTimeoutMillis"` // overrides `Config.ResolverTimeoutMillis`
+	MinResolvedIPs          int              `json:"minResolvedIps"`        // resolves the server even without `Config.ReportDNS`
+	PinnedIPs               []string         `json:"pinnedIps"`             // the exact set to which the server must resolve
+	SourceAddress           string           `json:"sourceAddress"`         // overrides `Config.SourceAddress`
+	Recipients              []string         `json:"recipients"`
+	SMSNumbers              []string         `json:"smsNumbers"`
+	Notifiers               []string         `json:"notifiers"` // names; all, if empty
+	FromOverride            *FromIdentity    `json:"fromOverride"`
+	Invert                  bool             `json:"invert"` // expect the server to be unreachable
+	Tags                    []string         `json:"tags"`
+	AlertHours              *AlertHours      `json:"alertHours"`          // alert at any time, if absent
+	IntervalSeconds         int              `json:"intervalSeconds"`     // overrides `Config.HeartbeatSeconds`
+	FastIntervalSeconds     int              `json:"fastIntervalSeconds"` // while never succeeded, or failing
+	Group                   string           `json:"group"`
+	MutexGroup              string           `json:"mutexGroup"` // sites sharing one are never checked concurrently
+	DependsOn               string           `json:"dependsOn"`  // name of a site; skipped while that, or any site expanded from it, is failing
+	// origin is the name of the site from whose servers this one was
+	// expanded, if any.
+	origin string
 }
 
 // HTTPConfig specifies configuration for `http` and `https` services.
 type HTTPConfig struct {
-	Port       int             `json:"port"`
-	URL        string          `json:"url"`
-	Method     string          `json:"method"`
-	Body       json.RawMessage `json:"body"`
-	Accept403  bool            `json:"accept403"`
-	VerifyCert bool            `json:"verifyCert"`
+	Port               int               `json:"port"`
+	URL                string            `json:"url"`
+	URLs               []string          `json:"urls"`
+	Method             string            `json:"method"`
+	Body               json.RawMessage   `json:"body"`
+	BodyFile           string            `json:"bodyFile"`        // overrides `body`
+	BearerTokenFile    string            `json:"bearerTokenFile"` // read on each check
+	Accept403          bool              `json:"accept403"`
+	StatusPolicies     map[string]string `json:"statusPolicies"`     // "429" or "5xx" -> ok, warn, retry, maintenance or fail
+	StatusRetries      int               `json:"statusRetries"`      // for `retry`; default 1
+	VerifyCert         *bool             `json:"verifyCert"`         // overrides `Config.DefaultVerifyCert`
+	MinTLSVersion      string            `json:"minTlsVersion"`      // "1.0" through "1.3"
+	DialTimeoutMillis  int64             `json:"dialTimeoutMillis"`  // default `totalTimeoutMillis`
+	CACertFile         string            `json:"caCertFile"`         // overrides `Config.CACertFile`
+	CACertDir          string            `json:"caCertDir"`          // overrides `Config.CACertDir`
+	TotalTimeoutMillis int64             `json:"totalTimeoutMillis"` // whole exchange; default twice `Site.TimeoutMillis`
+	DebugDump          bool              `json:"debugDump"`          // log request and response details
+	ExpectJSONField    string            `json:"expectJsonField"`    // dotted path, e.g. `status`
+	ExpectJSONValue    string            `json:"expectJsonValue"`
+	// RemoteWriteProbe sends a single sample to a Prometheus remote-write
+	// endpoint, instead of the configured body.  The method defaults to
+	// POST.
+	RemoteWriteProbe bool `json:"remoteWriteProbe"`
+	// ProbesPerCheck is the number of requests made in each check.  The
+	// timings of the successful probes are aggregated as per Aggregation:
+	// `min`, `median` (default) or `mean`.  With `min`, one success makes
+	// the site up; otherwise, most of the probes must succeed.
+	ProbesPerCheck int    `json:"probesPerCheck"`
+	Aggregation    string `json:"aggregation"`
+	// ResponseSchemaFile is a JSON Schema to which the response body must
+	// conform.
+	ResponseSchemaFile string `json:"responseSchemaFile"`
+	// ExpectedSHA256 is the hex-encoded checksum of the entire response
+	// body, which is streamed, rather than capped.  Allow for the download
+	// in TotalTimeoutMillis.
+	ExpectedSHA256 string `json:"expectedSha256"`
+	// KeepAlive reuses connections across checks, so that warm-path
+	// latency is measured.  By default, every check connects afresh.
+	KeepAlive bool `json:"keepAlive"`
+	// CompareWith is the full URL of a stable endpoint, with whose
+	// response that of this, the canary, is compared.  Divergence in
+	// status, or in more than CompareTolerance of the body lines (0 to 1),
+	// is alerted.
+	CompareWith      string  `json:"compareWith"`
+	CompareTolerance float64 `json:"compareTolerance"`
+	// SOCKS5Proxy is the `host:port` of a SOCKS5 proxy through which the
+	// server is reached, with optional credentials.
+	SOCKS5Proxy    string `json:"socks5Proxy"`
+	SOCKS5Username string `json:"socks5Username"`
+	SOCKS5Password string `json:"socks5Password"`
+
+	// ExpectedCertFingerprint is the hex-encoded SHA-256 fingerprint of
+	// the leaf certificate that the server must present.  Colons are
+	// optional.
+	ExpectedCertFingerprint string `json:"expectedCertFingerprint"`
+	// ExpectedIssuer is the common name, or the full distinguished name,
+	// of the issuer of the leaf certificate.
+	ExpectedIssuer string `json:"expectedIssuer"`
+	// TrackContentHash requests an alert when the response body changes
+	// between successive checks.
+	TrackContentHash bool `json:"trackContentHash"`
+	// LatencyWindowSize is the number of recent checks over which the 95th
+	// percentile of total latency is compared to P95ThresholdMillis.
+	LatencyWindowSize  int   `json:"latencyWindowSize"`
+	P95ThresholdMillis int64 `json:"p95ThresholdMillis"`
+	// AnomalyStdDevThreshold requests an alert when the total latency
+	// exceeds its rolling mean by more than these many standard
+	// deviations.  Detection begins after AnomalyMinSamples checks.
+	AnomalyStdDevThreshold float64 `json:"anomalyStdDevThreshold"`
+	AnomalyMinSamples      int64   `json:"anomalyMinSamples"` // default 30
 }
 
 // MySQLConfig specifies configuration for MySQL services.
 type MySQLConfig struct {
+	Port                 int    `json:"port"`
+	Username             string `json:"username"`
+	Password             string `json:"password"`
+	SlowThresholdMillis  int64  `json:"slowThresholdMillis"`
+	Retries              int    `json:"retries"`              // network errors only
+	ConnectTimeoutMillis int64  `json:"connectTimeoutMillis"` // default `Site.TimeoutMillis`
+	QueryTimeoutMillis   int64  `json:"queryTimeoutMillis"`   // default `Site.TimeoutMillis`
+}
+
+// OracleConfig specifies configuration for Oracle Database services.  The
+// database is identified by its service name or, failing that, its SID.
+type OracleConfig struct {
+	Port                 int    `json:"port"` // usually 1521
+	ServiceName          string `json:"serviceName"`
+	SID                  string `json:"sid"`
+	Username             string `json:"username"`
+	Password             string `json:"password"`
+	SlowThresholdMillis  int64  `json:"slowThresholdMillis"`
+	Retries              int    `json:"retries"`              // network errors only
+	ConnectTimeoutMillis int64  `json:"connectTimeoutMillis"` // default `Site.TimeoutMillis`
+	QueryTimeoutMillis   int64  `json:"queryTimeoutMillis"`   // default `Site.TimeoutMillis`
+}
+
+// NTPConfig specifies configuration for checking an NTP server, and the
+// offset of the local clock from it.
+type NTPConfig struct {
+	Port            int   `json:"port"`            // default 123
+	MaxOffsetMillis int64 `json:"maxOffsetMillis"` // either way; unchecked, if zero
+}
+
+// SQLServerConfig specifies configuration for SQL Server services.
+type SQLServerConfig struct {
+	Port                   int    `json:"port"`
+	Username               string `json:"username"`
+	Password               string `json:"password"`
+	SlowThresholdMillis    int64  `json:"slowThresholdMillis"`
+	Retries                int    `json:"retries"`              // network errors only
+	ConnectTimeoutMillis   int64  `json:"connectTimeoutMillis"` // default `Site.TimeoutMillis`
+	QueryTimeoutMillis     int64  `json:"queryTimeoutMillis"`   // default `Site.TimeoutMillis`
+	Instance               string `json:"instance"`
+	Encrypt                string `json:"encrypt"` // "true", "false" or "disable"
+	TrustServerCertificate bool   `json:"trustServerCertificate"`
+}
+
+// WebSocketConfig specifies configuration for `ws` and `wss` services.
+type WebSocketConfig struct {
+	Port        int    `json:"port"`
+	Path        string `json:"path"`
+	Subprotocol string `json:"subprotocol"`
+	Ping        bool   `json:"ping"`
+	VerifyCert  *bool  `json:"verifyCert"`
+}
+
+// MQTTConfig specifies configuration for MQTT brokers.
+type MQTTConfig struct {
+	Port       int    `json:"port"`
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	TLS        bool   `json:"tls"`
+	VerifyCert *bool  `json:"verifyCert"`
+	Topic      string `json:"topic"`
+}
+
+// MemcachedConfig specifies configuration for memcached services.
+type MemcachedConfig struct {
+	Port int `json:"port"`
+}
+
+// NATSConfig specifies configuration for NATS servers.
+type NATSConfig struct {
 	Port     int    `json:"port"`
 	Username string `json:"username"`
 	Password string `json:"password"`
+	Token    string `json:"token"`
 }
 
-// SQLServerConfig specifies configuration for SQL Server services.
-type SQLServerConfig struct {
+// SSHConfig specifies configuration for checks that run a command over
+// SSH.
+type SSHConfig struct {
+	Port           int    `json:"port"`
+	Username       string `json:"username"`
+	Password       string `json:"password"`
+	KeyFile        string `json:"keyFile"`
+	KnownHostsFile string `json:"knownHostsFile"`
+	Command        string `json:"command"`
+	ExpectPattern  string `json:"expectPattern"`
+}
+
+// PostgresConfig specifies configuration for PostgreSQL services.
+type PostgresConfig struct {
+	Port                     int     `json:"port"`
+	Username                 string  `json:"username"`
+	Password                 string  `json:"password"`
+	Database                 string  `json:"database"`
+	SSLMode                  string  `json:"sslMode"`
+	MaxReplicationLagSeconds float64 `json:"maxReplicationLagSeconds"`
+}
+
+// ClickHouseConfig specifies configuration for ClickHouse services.
+type ClickHouseConfig struct {
 	Port     int    `json:"port"`
 	Username string `json:"username"`
 	Password string `json:"password"`
+	Database string `json:"database"`
+}
+
+// LDAPConfig specifies configuration for LDAP and Active Directory
+// services.
+type LDAPConfig struct {
+	Port       int    `json:"port"`
+	TLS        bool   `json:"tls"`
+	StartTLS   bool   `json:"startTls"`
+	VerifyCert *bool  `json:"verifyCert"`
+	BindDN     string `json:"bindDn"`
+	Password   string `json:"password"`
+	BaseDN     string `json:"baseDn"`
+}
+
+// ConsulConfig specifies configuration for checking the health of a
+// service registered with Consul.
+type ConsulConfig struct {
+	Port       int    `json:"port"`
+	TLS        bool   `json:"tls"`
+	VerifyCert *bool  `json:"verifyCert"`
+	Service    string `json:"service"`
+	Datacenter string `json:"datacenter"`
+	Token      string `json:"token"`
+	MinPassing int    `json:"minPassing"` // default 1
+}
+
+// EtcdConfig specifies configuration for etcd clusters.  When no
+// endpoints are specified, the site's server and port are used.
+type EtcdConfig struct {
+	Port       int      `json:"port"`
+	Endpoints  []string `json:"endpoints"`
+	Username   string   `json:"username"`
+	Password   string   `json:"password"`
+	TLS        bool     `json:"tls"`
+	VerifyCert *bool    `json:"verifyCert"`
+}
+
+// TCPConfig specifies configuration for plain TCP services.
+type TCPConfig struct {
+	Port  int   `json:"port"`
+	Ports []int `json:"ports"` // overrides `port`; all must be open
+
+	ConnectTimeoutMillis int64 `json:"connectTimeoutMillis"` // default `Site.TimeoutMillis`
+	// ReadTimeoutMillis, when specified, requires the server to send some
+	// data, such as a banner, within this time of connecting.
+	ReadTimeoutMillis int64 `json:"readTimeoutMillis"`
+}
+
+// AMQPConfig specifies configuration for AMQP brokers, such as RabbitMQ.
+type AMQPConfig struct {
+	Port       int    `json:"port"`
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	VHost      string `json:"vhost"`
+	Queue      string `json:"queue"`
+	TLS        bool   `json:"tls"`
+	VerifyCert *bool  `json:"verifyCert"`
+}
+
+// CassandraConfig specifies configuration for Cassandra and ScyllaDB
+// clusters.  When no hosts are specified, the site's server is used.
+type CassandraConfig struct {
+	Port        int      `json:"port"` // default 9042
+	Hosts       []string `json:"hosts"`
+	Keyspace    string   `json:"keyspace"`
+	Username    string   `json:"username"`
+	Password    string   `json:"password"`
+	Consistency string   `json:"consistency"` // e.g. "ONE", "QUORUM"
+	Query       bool     `json:"query"`       // run a lightweight query
+}
+
+// DockerConfig specifies configuration for checking a Docker daemon, and
+// optionally one of its containers.  The daemon is reached through its
+// unix socket, if specified; otherwise, over TCP at the site's server.
+type DockerConfig struct {
+	Socket     string `json:"socket"` // e.g. `/var/run/docker.sock`
+	Port       int    `json:"port"`
+	TLS        bool   `json:"tls"`
+	VerifyCert *bool  `json:"verifyCert"`
+	Container  string `json:"container"` // name or ID; must be running
+}
+
+// KubernetesConfig specifies configuration for checking a Kubernetes API
+// server.  It authenticates with a bearer token, or a client certificate.
+type KubernetesConfig struct {
+	Port           int      `json:"port"` // e.g. 6443
+	Token          string   `json:"token"`
+	TokenFile      string   `json:"tokenFile"` // read on each check; overrides `token`
+	CACertFile     string   `json:"caCertFile"`
+	ClientCertFile string   `json:"clientCertFile"`
+	ClientKeyFile  string   `json:"clientKeyFile"`
+	VerifyCert     *bool    `json:"verifyCert"`
+	Components     []string `json:"components"` // `/readyz` checks that must pass, e.g. "etcd"; all, if empty
+}
+
+// GRPCConfig specifies configuration for gRPC services.  The standard
+// health service is called, unless a method is specified.
+type GRPCConfig struct {
+	Port       int    `json:"port"`
+	TLS        bool   `json:"tls"`
+	VerifyCert *bool  `json:"verifyCert"`
+	Service    string `json:"service"` // for the health service; the server as a whole, if empty
+	// Method is the full path of a unary method to invoke instead, e.g.
+	// `/package.Service/Method`.  Any non-error response is healthy.
+	Method string `json:"method"`
+	// Request is the request message, in its JSON form; empty, if not
+	// specified.  Its type is resolved through server reflection, or
+	// from a binary descriptor set (`protoc --include_imports
+	// --descriptor_set_out`).
+	Request           string `json:"request"`
+	Reflection        bool   `json:"reflection"`
+	DescriptorSetFile string `json:"descriptorSetFile"`
+}
+
+// NotifierConfig specifies an additional channel through which alerts
+// are dispatched.
+type NotifierConfig struct {
+	Name      string          `json:"name"`
+	Type      string          `json:"type"`
+	SNS       SNSConfig       `json:"sns"`
+	Twilio    TwilioConfig    `json:"twilio"`
+	PagerDuty PagerDutyConfig `json:"pagerduty"`
+}
+
+// SNSConfig specifies configuration for alerting via AWS SNS.
+type SNSConfig struct {
+	TopicARN string `json:"topicArn"`
+	Region   string `json:"region"`
+}
+
+// TwilioConfig specifies configuration for alerting via SMS, through
+// Twilio.
+type TwilioConfig struct {
+	AccountSID string   `json:"accountSid"`
+	AuthToken  string   `json:"authToken"`
+	From       string   `json:"from"`
+	To         []string `json:"to"` // unless the site specifies its own
+}
+
+// PagerDutyConfig specifies configuration for alerting via PagerDuty's
+// Events API.  An incident is opened per site, and resolved once the site
+// has been healthy for the given period.
+type PagerDutyConfig struct {
+	RoutingKey                 string `json:"routingKey"`
+	ResolveAfterHealthySeconds int    `json:"resolveAfterHealthySeconds"` // 0 resolves on the first success
+}
+
+// RouteRule adds notifiers and recipients to the alerts of sites that
+// carry any of its tags.
+type RouteRule struct {
+	Tags       []string `json:"tags"`
+	Notifiers  []string `json:"notifiers"`
+	Recipients []string `json:"recipients"`
+}
+
+// TracingConfig specifies the OTLP collector to which check spans are
+// exported.  Tracing is disabled in the absence of an endpoint.
+type TracingConfig struct {
+	Endpoint    string `json:"endpoint"` // host:port of the OTLP/HTTP receiver
+	Insecure    bool   `json:"insecure"` // use plain HTTP
+	ServiceName string `json:"serviceName"`
+}
+
+// ResultHookConfig specifies the endpoint to which the outcome of every
+// check is posted, signed with the shared secret.
+type ResultHookConfig struct {
+	URL           string `json:"url"`
+	Secret        string `json:"secret"`
+	TimeoutMillis int    `json:"timeoutMillis"` // default 5000
+}
+
+// GroupConfig specifies the scheduling and default recipients shared by
+// the sites that reference the group.  Sites may override them.
+type GroupConfig struct {
+	Name            string   `json:"name"`
+	IntervalSeconds int      `json:"intervalSeconds"`
+	Concurrency     int      `json:"concurrency"` // concurrent checks; unlimited, if 0
+	Recipients      []string `json:"recipients"`  // for sites without their own
 }
 
 // Config holds the monitor's configuration.
 type Config struct {
-	Sender                SenderConfig `json:"sender"`
-	HeartbeatSeconds      int          `json:"heartbeatSeconds"`
-	ResolverAddress       string       `json:"resolverAddress"`
-	ResolverTimeoutMillis int          `json:"resolverTimeoutMillis"`
-	ReportDNS             bool         `json:"reportDns"`
-	Sites                 []Site       `json:"sites"`
+	Sender                SenderConfig     `json:"sender"`
+	HeartbeatSeconds      int              `json:"heartbeatSeconds"`
+	ResolverAddress       string           `json:"resolverAddress"`
+	ResolverTimeoutMillis int              `json:"resolverTimeoutMillis"`
+	DNSRetries            int              `json:"dnsRetries"`          // before a DNS failure is alerted
+	DNSRetryDelayMillis   int              `json:"dnsRetryDelayMillis"` // default 200
+	ReportDNS             bool             `json:"reportDns"`
+	SitesSourceURL        string           `json:"sitesSourceUrl"`      // fetched sites are merged with `Sites`
+	SitesRefreshSeconds   int              `json:"sitesRefreshSeconds"` // default 300
+	SourceAddress         string           `json:"sourceAddress"`       // local IP from which checks originate
+	CombineDNSAlerts      bool             `json:"combineDnsAlerts"`
+	StatusPort            int              `json:"statusPort"`
+	StatusServerRequired  bool             `json:"statusServerRequired"` // refuse to start without it
+	DefaultVerifyCert     bool             `json:"defaultVerifyCert"`
+	CACertFile            string           `json:"caCertFile"` // trusted in addition to the system CAs
+	CACertDir             string           `json:"caCertDir"`  // `.pem` and `.crt` files
+	Defaults              map[string]int64 `json:"defaults"`   // protocol -> timeout in ms
+	Notifiers             []NotifierConfig `json:"notifiers"`
+	LogDir                string           `json:"logDir"`
+	LogFallback           string           `json:"logFallback"`         // "stderr" to continue if the log directory is unusable; else, exit
+	Timezone              string           `json:"timezone"`            // IANA name, for times in alerts; local, if empty
+	LogTimezone           bool             `json:"logTimezone"`         // use `Timezone` for log timestamps, too
+	AdminRecipients       []string         `json:"adminRecipients"`     // notified if the monitor cannot start properly
+	Environment           string           `json:"environment"`         // prefixed to alert subjects, e.g. "PROD"
+	DigestIntervalHours   int              `json:"digestIntervalHours"` // summary digest period, e.g. 24; none, if 0
+	DigestRecipients      []string         `json:"digestRecipients"`    // default `AdminRecipients`
+	Strict                *bool            `json:"strict"`              // refuse to start on invalid sites; default true
+	StartupGraceSeconds   int              `json:"startupGraceSeconds"`
+	Routes                []RouteRule      `json:"routes"`
+	Groups                []GroupConfig    `json:"groups"`
+	LatencyEMAAlpha       float64          `json:"latencyEmaAlpha"` // smoothing factor in (0, 1]; default 0.2
+	Tracing               TracingConfig    `json:"tracing"`
+	ResultWebhook         ResultHookConfig `json:"resultWebhook"` // every check result; none, if no URL
+	Sites                 []Site           `json:"sites"`
 }
 
 // Monitor monitors the heartbeat of the servers specified in the
@@ -68,6 +488,40 @@ type Monitor struct {
 	conf       *Config
 	mailServer string
 	resolver   *net.Resolver
+	rootCAs    *x509.CertPool                // nil for the system pool
+	schemas    map[string]*jsonschema.Schema // by file
+	caPools    map[string]*x509.CertPool     // per-site, by file and directory
+	notifiers  []Notifier
+	clock      Clock
+	startedAt  time.Time
+	server     *http.Server
+	hostname   string
+	location   *time.Location // for times in alerts
+	quiet      bool           // no progress output
+	summary    bool           // one line per sweep, instead of a dot
+
+	staticSites    []Site // `Config.Sites`, once fetched ones are merged in
+	sitesFetchedAt time.Time
+	refreshing     atomic.Bool   // a fetch of the site list is in progress
+	sitesChanged   chan struct{} // signalled as fetched sites are swapped in
+	digestFrom     time.Time     // start of the current digest period
+
+	tracer         trace.Tracer
+	tracerProvider *sdktrace.TracerProvider
+
+	mu              sync.Mutex
+	states          map[string]*siteState
+	sweeps          int64
+	lastSweepMillis int64
+	lastSweepAt     time.Time
+	deferred        map[string]*Alert // outside alert hours
+	digestDeferred  []*Alert          // deferred in the current digest period
+	sweeping        atomic.Bool
+	spoolMu         sync.Mutex
+	groupSlots      map[string]chan struct{} // bounds concurrent checks per group
+	mutexes         sync.Map                 // mutex group -> *sync.Mutex
+	transports      sync.Map                 // site key -> *http.Transport, for keep-alives
+	paused          atomic.Bool
 }
 
 //////////////////////////////////////////////////////////////////////
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+
+	"github.com/gocql/gocql"
+	"go.uber.org/zap"
+)
+
+// ErrConfig indicates a problem with the configuration, rather than with
+// the monitored site.
+var ErrConfig = errors.New("configuration error")
+
+// protocolFamilies maps each supported protocol to its family.
+var protocolFamilies = map[string]string{
+	"http":       "http",
+	"https":      "http",
+	"mysql":      "mysql",
+	"sqlserver":  "sqlserver",
+	"websocket":  "websocket",
+	"ws":         "websocket",
+	"wss":        "websocket",
+	"mqtt":       "mqtt",
+	"memcached":  "memcached",
+	"nats":       "nats",
+	"ssh":        "ssh",
+	"postgres":   "postgres",
+	"clickhouse": "clickhouse",
+	"ldap":       "ldap",
+	"consul":     "consul",
+	"etcd":       "etcd",
+	"tcp":        "tcp",
+	"amqp":       "amqp",
+	"cassandra":  "cassandra",
+	"scylla":     "cassandra",
+	"docker":     "docker",
+	"kubernetes": "kubernetes",
+	"grpc":       "grpc",
+	"oracle":     "oracle",
+	"ntp":        "ntp",
+}
+
+// validStatusKey matches an HTTP status code, or a class of codes.
+var validStatusKey = regexp.MustCompile(`^[1-5]([0-9]{2}|xx)$`)
+
+// validSHA256 matches a hex-encoded SHA-256 checksum.
+var validSHA256 = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// validate checks the configuration for problems that would otherwise
+// surface only when checking the sites.  In strict mode, all the problems
+// found are reported together.  Otherwise, invalid sites are dropped with
+// a warning.
+func (c *Config) validate() error {
+	strict := c.Strict == nil || *c.Strict
+
+	var errs []error
+	if c.SourceAddress != "" && net.ParseIP(c.SourceAddress) == nil {
+		errs = append(errs, fmt.Errorf("%w: invalid source address: %s", ErrConfig, c.SourceAddress))
+	}
+	if c.ResultWebhook.URL != "" && c.ResultWebhook.Secret == "" {
+		errs = append(errs, fmt.Errorf("%w: result webhook secret not specified", ErrConfig))
+	}
+	if c.DNSRetries < 0 || c.DNSRetryDelayMillis < 0 {
+		errs = append(errs, fmt.Errorf("%w: negative DNS retries or delay", ErrConfig))
+	}
+	groups := make(map[string]bool, len(c.Groups))
+	for _, g := range c.Groups {
+		switch {
+		case g.Name == "":
+			errs = append(errs, fmt.Errorf("%w: group name not specified", ErrConfig))
+		case groups[g.Name]:
+			errs = append(errs, fmt.Errorf("%w: duplicate group: %s", ErrConfig, g.Name))
+		case g.IntervalSeconds < 0 || g.Concurrency < 0:
+			errs = append(errs, fmt.Errorf("%w: group %s : negative interval or concurrency", ErrConfig, g.Name))
+		}
+		groups[g.Name] = true
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	c.Sites = expandServers(c.Sites)
+	valid := make([]Site, 0, len(c.Sites))
+	keys := make(map[string]bool, len(c.Sites))
+	for i := range c.Sites {
+		err := c.Sites[i].validate()
+		if g := c.Sites[i].Group; err == nil && g != "" && !groups[g] {
+			err = fmt.Errorf("%w: unknown group: %s", ErrConfig, g)
+		}
+		if k := c.Sites[i].key(); err == nil && keys[k] {
+			err = fmt.Errorf("%w: duplicate site: %s (name the sites apart)", ErrConfig, k)
+		}
+		if err != nil {
+			err = fmt.Errorf("site #%d (%s) : %w", i+1, c.Sites[i].Server, err)
+			if strict {
+				errs = append(errs, err)
+			} else {
+				fmt.Printf("!! Skipping invalid site : %s\n", err.Error())
+				zLog.Warn("config",
+					zap.String("uri", c.Sites[i].Server),
+					zap.String("error", err.Error()))
+			}
+			continue
+		}
+		keys[c.Sites[i].key()] = true
+		valid = append(valid, c.Sites[i])
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	if err := validateDependencies(valid); err != nil {
+		return err
+	}
+
+	c.Sites = valid
+	return nil
+}
+
+// validate checks the site's configuration for missing or invalid
+// fields.
+func (s *Site) validate() error {
+	if s.Server == "" {
+		return fmt.Errorf("%w: server not specified", ErrConfig)
+	}
+	family, ok := protocolFamilies[s.Protocol]
+	if !ok {
+		return fmt.Errorf("%w: unhandled protocol: %s", ErrConfig, s.Protocol)
+	}
+
+	port := s.port()
+	switch family {
+	case "sqlserver":
+		if s.SQLServerConfig.Instance != "" {
+			port = -1 // resolved by SQL Server Browser
+		}
+	case "tcp":
+		if len(s.TCPConfig.Ports) > 0 {
+			port = -1 // checked below
+		}
+	case "docker":
+		if s.DockerConfig.Socket != "" {
+			port = -1 // unix socket
+		}
+	case "etcd":
+		if len(s.EtcdConfig.Endpoints) > 0 {
+			port = -1 // endpoints carry their own ports
+		}
+	case "http", "websocket", "cassandra", "ntp":
+		port = -1 // optional
+	}
+	if port == 0 {
+		return fmt.Errorf("%w: port not specified for protocol: %s", ErrConfig, s.Protocol)
+	}
+
+	if family == "cassandra" && s.CassandraConfig.Consistency != "" {
+		if _, err := gocql.ParseConsistencyWrapper(s.CassandraConfig.Consistency); err != nil {
+			return fmt.Errorf("%w: %s", ErrConfig, err.Error())
+		}
+	}
+	if gc := &s.GRPCConfig; family == "grpc" && gc.Method != "" {
+		if _, _, ok := splitGRPCMethod(gc.Method); !ok {
+			return fmt.Errorf("%w: invalid method: %s (expected /package.Service/Method)", ErrConfig, gc.Method)
+		}
+		if gc.Request != "" && !gc.Reflection && gc.DescriptorSetFile == "" {
+			return fmt.Errorf("%w: a request needs reflection, or a descriptor set", ErrConfig)
+		}
+	}
+	if oc := &s.OracleConfig; family == "oracle" && (oc.ServiceName == "") == (oc.SID == "") {
+		return fmt.Errorf("%w: exactly one of service name and SID must be specified", ErrConfig)
+	}
+	if nc := &s.NTPConfig; family == "ntp" && (nc.Port < 0 || nc.Port > 65535 || nc.MaxOffsetMillis < 0) {
+		return fmt.Errorf("%w: invalid port or maximum offset", ErrConfig)
+	}
+	if family == "kubernetes" && (s.KubernetesConfig.ClientCertFile == "") != (s.KubernetesConfig.ClientKeyFile == "") {
+		return fmt.Errorf("%w: client certificate and key must be specified together", ErrConfig)
+	}
+	if family == "tcp" {
+		for _, p := range s.TCPConfig.Ports {
+			if p <= 0 || p > 65535 {
+				return fmt.Errorf("%w: invalid port: %d", ErrConfig, p)
+			}
+		}
+	}
+	if family == "consul" && s.ConsulConfig.Service == "" {
+		return fmt.Errorf("%w: service not specified", ErrConfig)
+	}
+	if family == "ssh" {
+		if s.SSHConfig.Command == "" {
+			return fmt.Errorf("%w: command not specified", ErrConfig)
+		}
+		if _, err := regexp.Compile(s.SSHConfig.ExpectPattern); err != nil {
+			return fmt.Errorf("%w: invalid expected pattern: %s", ErrConfig, err.Error())
+		}
+	}
+	if family == "http" {
+		if _, err := parseTLSVersion(s.HTTPConfig.MinTLSVersion); err != nil {
+			return fmt.Errorf("%w: %s", ErrConfig, err.Error())
+		}
+		switch s.HTTPConfig.Aggregation {
+		case "", aggMin, aggMedian, aggMean:
+		default:
+			return fmt.Errorf("%w: invalid aggregation: %s", ErrConfig, s.HTTPConfig.Aggregation)
+		}
+		if v := s.HTTPConfig.SOCKS5Proxy; v != "" {
+			if _, _, err := net.SplitHostPort(v); err != nil {
+				return fmt.Errorf("%w: invalid SOCKS5 proxy: %s", ErrConfig, v)
+			}
+		}
+		if v := s.HTTPConfig.CompareWith; v != "" {
+			if u, err := url.Parse(v); err != nil || u.Scheme == "" || u.Host == "" {
+				return fmt.Errorf("%w: invalid comparison URL: %s", ErrConfig, v)
+			}
+		}
+		if t := s.HTTPConfig.CompareTolerance; t < 0 || t > 1 {
+			return fmt.Errorf("%w: comparison tolerance must be between 0 and 1", ErrConfig)
+		}
+		if v := s.HTTPConfig.ExpectedSHA256; v != "" && !validSHA256.MatchString(v) {
+			return fmt.Errorf("%w: invalid SHA-256 checksum: %s", ErrConfig, v)
+		}
+		if s.HTTPConfig.AnomalyStdDevThreshold < 0 || s.HTTPConfig.AnomalyMinSamples < 0 {
+			return fmt.Errorf("%w: negative anomaly threshold or sample count", ErrConfig)
+		}
+		for code, action := range s.HTTPConfig.StatusPolicies {
+			if !validStatusKey.MatchString(code) {
+				return fmt.Errorf("%w: invalid status code: %s", ErrConfig, code)
+			}
+			switch action {
+			case statusOK, statusWarn, statusRetry, statusMaintenance, statusFail:
+			default:
+				return fmt.Errorf("%w: invalid action for status %s: %s", ErrConfig, code, action)
+			}
+		}
+	}
+	if s.IntervalSeconds < 0 || s.FastIntervalSeconds < 0 {
+		return fmt.Errorf("%w: negative interval", ErrConfig)
+	}
+	if s.AlertHours != nil {
+		if err := s.AlertHours.validate(); err != nil {
+			return fmt.Errorf("%w: %s", ErrConfig, err.Error())
+		}
+	}
+	for _, ip := range s.PinnedIPs {
+		if net.ParseIP(ip) == nil {
+			return fmt.Errorf("%w: invalid pinned address: %s", ErrConfig, ip)
+		}
+	}
+	if s.SourceAddress != "" && net.ParseIP(s.SourceAddress) == nil {
+		return fmt.Errorf("%w: invalid source address: %s", ErrConfig, s.SourceAddress)
+	}
+	if family == "http" && (s.HTTPConfig.CACertFile != "" || s.HTTPConfig.CACertDir != "") {
+		if _, err := loadCAPool(s.HTTPConfig.CACertFile, s.HTTPConfig.CACertDir); err != nil {
+			return fmt.Errorf("%w: CA certificates: %s", ErrConfig, err.Error())
+		}
+	}
+	if family == "http" && s.HTTPConfig.BodyFile != "" {
+		if _, err := os.Stat(s.HTTPConfig.BodyFile); err != nil {
+			return fmt.Errorf("%w: body file: %s", ErrConfig, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// expandServers expands each site that lists several servers into one
+// site per server, sharing the rest of the specification.  The name of
+// an expanded site, if any, is suffixed with its server, so that each
+// server's state is tracked separately.  The original name continues to
+// refer to all of them, as a dependency.
+func expandServers(sites []Site) []Site {
+	res := make([]Site, 0, len(sites))
+	for _, s := range sites {
+		if len(s.Servers) == 0 {
+			res = append(res, s)
+			continue
+		}
+
+		servers := s.Servers
+		if s.Server != "" && !slices.Contains(servers, s.Server) {
+			servers = append([]string{s.Server}, servers...)
+		}
+		for _, server := range servers {
+			e := s
+			e.Server, e.Servers = server, nil
+			if s.Name != "" {
+				e.Name, e.origin = s.Name+"@"+server, s.Name
+			}
+			res = append(res, e)
+		}
+	}
+	return res
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateRejectsDuplicateSites(t *testing.T) {
+	tcp := func(name string, port int) Site {
+		return Site{Name: name, Protocol: "tcp", Server: "h", TCPConfig: TCPConfig{Port: port}}
+	}
+
+	tests := []struct {
+		name  string
+		sites []Site
+		dup   bool
+	}{
+		{"different ports", []Site{tcp("", 22), tcp("", 80)}, false},
+		{"same port", []Site{tcp("", 22), tcp("", 22)}, true},
+		{"same port, named apart", []Site{tcp("a", 22), tcp("b", 22)}, false},
+		{"same name", []Site{tcp("a", 22), tcp("a", 80)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{Sites: tt.sites}
+			err := c.validate()
+			if got := err != nil && strings.Contains(err.Error(), "duplicate site"); got != tt.dup {
+				t.Fatalf("duplicate %v, expected %v; err: %v", got, tt.dup, err)
+			}
+			if tt.dup && !errors.Is(err, ErrConfig) {
+				t.Fatalf("not a configuration error: %v", err)
+			}
+		})
+	}
+}